@@ -4,27 +4,49 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
+	"aniki/internal/api"
 	"aniki/internal/config"
 	"aniki/internal/database"
 	"aniki/internal/hand_history"
+	"aniki/internal/hud"
+	"aniki/internal/replay"
 	"aniki/internal/repository"
+	"aniki/internal/search"
 	"aniki/internal/watcher"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// EventHandsImported is emitted to the frontend with a watcher.ImportEvent
+// payload every time the watcher finishes importing a file
+const EventHandsImported = "hands:imported"
+
+// EventDatabaseSwitched is emitted with the new database path after the
+// active database changes, so views can reload their data
+const EventDatabaseSwitched = "database:switched"
+
 // App struct
 type App struct {
-	ctx        context.Context
-	db         *database.DB
-	config     *config.Config
-	watcher    *watcher.Watcher
-	parser     *hand_history.Manager
-	siteRepo   repository.SiteRepository
-	handRepo   repository.HandRepository
-	playerRepo repository.PlayerRepository
-	actionRepo repository.ActionRepository
+	ctx          context.Context
+	mu           sync.RWMutex // guards the database, repositories and watcher
+	backupCancel context.CancelFunc
+	db           *database.DB
+	dbPath       string
+	config       *config.Config
+	watcher      *watcher.Watcher
+	parser       *hand_history.Manager
+	siteRepo     repository.SiteRepository
+	handRepo     repository.HandRepository
+	playerRepo   repository.PlayerRepository
+	actionRepo   repository.ActionRepository
+	sessionRepo  repository.SessionRepository
+	opponentRepo repository.OpponentRepository
+	noteRepo     repository.NoteRepository
+	hud          *hud.Service
+	api          *api.Server
 }
 
 // NewApp creates a new App application struct
@@ -45,60 +67,181 @@ func (a *App) startup(ctx context.Context) {
 	}
 	a.config = cfg
 
-	// Initialize database
-	dbPath, err := config.GetDatabasePath()
+	// Initialize parser
+	a.parser = hand_history.NewManager()
+
+	a.hud = hud.NewService(func(table hud.Table) {
+		runtime.EventsEmit(a.ctx, EventHUDUpdated, table)
+	})
+	a.api = api.NewServer(a)
+
+	// Initialize database, repositories and file watcher
+	dbPath, err := a.config.ResolveDatabasePath()
 	if err != nil {
 		log.Fatalf("Failed to get database path: %v", err)
 	}
 
-	db, err := database.New(dbPath)
-	if err != nil {
+	if _, ok := a.config.FindDatabase(dbPath); !ok {
+		// Configs written before databases were registered only know
+		// the active path
+		a.config.AddDatabase("Default", dbPath)
+	}
+
+	if err := a.openDatabase(dbPath); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	a.db = db
 
-	// Initialize repositories
-	a.siteRepo = repository.NewSiteRepository(db.DB)
-	a.handRepo = repository.NewHandRepository(db.DB)
-	a.playerRepo = repository.NewPlayerRepository(db.DB)
-	a.actionRepo = repository.NewActionRepository(db.DB)
+	a.startBackupSchedule()
+	a.applyHUDConfig()
+	a.applyAPIConfig()
 
-	// Initialize parser
-	a.parser = hand_history.NewManager()
+	log.Println("Application started successfully")
+}
 
-	// Initialize file watcher
-	w, err := watcher.New(a.parser, a.siteRepo, a.handRepo, a.playerRepo, a.actionRepo)
+// shutdown is called when the app is closing
+func (a *App) shutdown(ctx context.Context) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopBackupSchedule()
+	if err := a.hud.Close(); err != nil {
+		log.Printf("Error stopping HUD: %v", err)
+	}
+	if err := a.api.Close(); err != nil {
+		log.Printf("Error stopping API: %v", err)
+	}
+	a.closeDatabase(ctx)
+	log.Println("Application shutdown complete")
+}
+
+// openDatabase opens the database at dbPath and binds the repositories and a
+// new file watcher to it, closing the previously open database only once
+// the new one is ready. The caller must hold a.mu for writing.
+func (a *App) openDatabase(dbPath string) error {
+	db, err := database.New(dbPath, a.databaseOptions())
 	if err != nil {
-		log.Fatalf("Failed to initialize watcher: %v", err)
+		return err
 	}
-	a.watcher = w
+
+	w, err := a.newWatcher(db)
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	a.closeDatabase(a.ctx)
+
+	a.db = db
+	a.dbPath = dbPath
+	a.hud.Reset()
+
+	a.setRepositories(db)
 
 	// Initialize default sites in database
 	a.initializeSites()
 
 	// Start watching configured paths
-	for _, site := range a.config.Sites {
-		if site.Enabled && site.WatchPath != "" {
-			if err := a.watcher.AddPath(site.WatchPath); err != nil {
-				log.Printf("Failed to watch path %s: %v", site.WatchPath, err)
-			}
-		}
+	a.watcher = w
+	a.watchConfiguredPaths()
+	a.watcher.Start()
+
+	log.Printf("Opened database: %s", dbPath)
+	return nil
+}
+
+// newWatcher creates a file watcher importing into db with the configured
+// watcher settings. The caller must hold a.mu.
+func (a *App) newWatcher(db *database.DB) (*watcher.Watcher, error) {
+	// HUD stats are read through the same database from watcher workers
+	hudStats := repository.NewOpponentRepository(db.DB)
+
+	w, err := watcher.New(a.parser,
+		repository.NewSiteRepository(db.DB),
+		repository.NewHandRepository(db.DB),
+		repository.NewPlayerRepository(db.DB),
+		repository.NewActionRepository(db.DB),
+		watcher.Options{
+			Workers:       a.config.Watcher.Workers,
+			QueueSize:     a.config.Watcher.QueueSize,
+			DebounceDelay: a.config.Watcher.Debounce(),
+			OnImport: func(event watcher.ImportEvent) {
+				a.emitImport(event)
+				a.updateHUD(event, hudStats)
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize watcher: %w", err)
+	}
+	return w, nil
+}
+
+// replaceWatcher stops the watcher, letting queued files finish importing,
+// and starts a new one with the configured watcher settings. Paths are
+// not carried over. The caller must hold a.mu for writing.
+func (a *App) replaceWatcher() error {
+	w, err := a.newWatcher(a.db)
+	if err != nil {
+		return err
+	}
+
+	drainCtx, cancel := context.WithTimeout(a.ctx, 10*time.Second)
+	defer cancel()
+	if err := a.watcher.Stop(drainCtx); err != nil {
+		log.Printf("Watcher did not drain cleanly: %v", err)
 	}
 
+	a.watcher = w
 	a.watcher.Start()
+	return nil
+}
 
-	log.Println("Application started successfully")
+// setRepositories binds the repositories to db. The caller must hold a.mu
+// for writing.
+func (a *App) setRepositories(db *database.DB) {
+	a.siteRepo = repository.NewSiteRepository(db.DB)
+	a.handRepo = repository.NewHandRepository(db.DB)
+	a.playerRepo = repository.NewPlayerRepository(db.DB)
+	a.actionRepo = repository.NewActionRepository(db.DB)
+	a.sessionRepo = repository.NewSessionRepository(db.DB)
+	a.opponentRepo = repository.NewOpponentRepository(db.DB)
+	a.noteRepo = repository.NewNoteRepository(db.DB)
 }
 
-// shutdown is called when the app is closing
-func (a *App) shutdown(ctx context.Context) {
+// closeDatabase stops the watcher and closes the open database, if any.
+// The caller must hold a.mu for writing.
+func (a *App) closeDatabase(ctx context.Context) {
 	if a.watcher != nil {
-		a.watcher.Stop()
+		// Give queued files a chance to be imported before closing the database
+		drainCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		if err := a.watcher.Stop(drainCtx); err != nil {
+			log.Printf("Watcher did not drain cleanly: %v", err)
+		}
+		cancel()
+		a.watcher = nil
 	}
 	if a.db != nil {
-		a.db.Close()
+		if err := a.db.Close(); err != nil {
+			log.Printf("Error closing database %s: %v", a.dbPath, err)
+		}
+		a.db = nil
+	}
+}
+
+// watchConfiguredPaths starts watching every enabled site's directory.
+// The caller must hold a.mu for writing.
+func (a *App) watchConfiguredPaths() {
+	for _, site := range a.config.Sites {
+		if site.Enabled && site.WatchPath != "" {
+			if err := a.watcher.AddPath(site.WatchPath, watchOptions(site)); err != nil {
+				log.Printf("Failed to watch path %s: %v", site.WatchPath, err)
+			}
+		}
 	}
-	log.Println("Application shutdown complete")
+}
+
+// emitImport forwards watcher import results to the frontend
+func (a *App) emitImport(event watcher.ImportEvent) {
+	runtime.EventsEmit(a.ctx, EventHandsImported, event)
 }
 
 // initializeSites ensures default sites exist in the database
@@ -126,28 +269,119 @@ func (a *App) initializeSites() {
 	}
 }
 
+// databaseOptions converts the database configuration into connection options
+func (a *App) databaseOptions() database.Options {
+	cfg := a.config.Database
+	return database.Options{
+		JournalMode:        cfg.JournalMode,
+		Synchronous:        cfg.Synchronous,
+		BusyTimeout:        time.Duration(cfg.BusyTimeoutMs) * time.Millisecond,
+		MaxReaders:         cfg.MaxReaders,
+		DisablePrepareStmt: cfg.DisableStatementCache,
+	}
+}
+
+// watchOptions converts per-site configuration into watcher path options
+func watchOptions(site config.Site) watcher.PathOptions {
+	return watcher.PathOptions{
+		MaxDepth:     site.MaxDepth,
+		Ignore:       site.IgnorePatterns,
+		Mode:         watcher.Mode(site.WatchMode),
+		PollInterval: time.Duration(site.PollInterval) * time.Second,
+	}
+}
+
 // GetHands retrieves hands based on the provided filter
 func (a *App) GetHands(filter database.HandFilter) ([]database.Hand, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	return a.handRepo.FindAll(filter)
 }
 
+// SearchHands returns one page of hands matching a search query such as
+// `pos:BTN cards:AKs result<-50bb`, along with the total number of matches
+func (a *App) SearchHands(query string, limit, offset int) (*database.HandPage, error) {
+	criteria, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	criteria.Limit = limit
+	criteria.Offset = offset
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.handRepo.Search(criteria)
+}
+
 // GetHandByID retrieves a single hand by ID
 func (a *App) GetHandByID(id int64) (*database.Hand, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	return a.handRepo.FindByID(id)
 }
 
+// GetHandReplay returns the table state of a hand after each action, for
+// stepping through it in the replayer
+func (a *App) GetHandReplay(id int64) ([]replay.Snapshot, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	hand, err := a.handRepo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hand: %w", err)
+	}
+	if hand == nil {
+		return nil, fmt.Errorf("hand %d not found", id)
+	}
+	return replay.Build(hand)
+}
+
 // GetStats retrieves statistics for a hero
 func (a *App) GetStats(heroName string) (*database.Stats, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	return a.handRepo.GetStats(heroName)
 }
 
+// GetProfitGraph returns the cumulative winnings graph of the hands
+// matching filter, per hand or bucketed by "day", "week" or "month"
+func (a *App) GetProfitGraph(filter database.HandFilter, interval string) (*database.ProfitGraph, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.handRepo.GetProfitGraph(filter, interval)
+}
+
+// GetGroupStats aggregates the hands matching filter in total or by
+// position, stakes or opponent
+func (a *App) GetGroupStats(filter database.HandFilter, group string) ([]database.GroupStats, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.handRepo.GetGroupStats(filter, group)
+}
+
 // GetConfig returns the current configuration
 func (a *App) GetConfig() *config.Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	return a.config
 }
 
-// UpdateConfig updates the configuration
+// UpdateConfig updates the configuration. The database list and active
+// database are managed by the database methods and are kept as they are.
 func (a *App) UpdateConfig(cfg *config.Config) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cfg.DatabasePath = a.config.DatabasePath
+	cfg.Databases = a.config.Databases
+
 	// Stop watching old paths
 	for _, site := range a.config.Sites {
 		if site.WatchPath != "" {
@@ -159,8 +393,16 @@ func (a *App) UpdateConfig(cfg *config.Config) error {
 	if err := cfg.Save(); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
+	previous := a.config
 	a.config = cfg
 
+	// Workers, queue size and debounce are fixed when a watcher is created
+	if cfg.Watcher != previous.Watcher {
+		if err := a.replaceWatcher(); err != nil {
+			log.Printf("Failed to apply watcher settings: %v", err)
+		}
+	}
+
 	// Update database sites
 	for _, siteCfg := range cfg.Sites {
 		site, err := a.siteRepo.FindByName(siteCfg.Name)
@@ -179,13 +421,11 @@ func (a *App) UpdateConfig(cfg *config.Config) error {
 	}
 
 	// Start watching new paths
-	for _, site := range cfg.Sites {
-		if site.Enabled && site.WatchPath != "" {
-			if err := a.watcher.AddPath(site.WatchPath); err != nil {
-				log.Printf("Failed to watch path %s: %v", site.WatchPath, err)
-			}
-		}
-	}
+	a.watchConfiguredPaths()
+
+	a.startBackupSchedule()
+	a.applyHUDConfig()
+	a.applyAPIConfig()
 
 	return nil
 }
@@ -200,10 +440,16 @@ func (a *App) SelectDirectory() (string, error) {
 
 // GetSites retrieves all sites from the database
 func (a *App) GetSites() ([]database.Site, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	return a.siteRepo.FindAll()
 }
 
 // GetWatcherStatus returns the current watcher status
-func (a *App) GetWatcherStatus() map[string]interface{} {
+func (a *App) GetWatcherStatus() watcher.Status {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	return a.watcher.GetStatus()
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+
+	"aniki/internal/api"
+)
+
+// applyAPIConfig starts or stops the REST API to match the configuration,
+// generating and saving a token when it is enabled without one. The
+// caller must hold a.mu for writing.
+func (a *App) applyAPIConfig() {
+	cfg := &a.config.API
+
+	if !cfg.Enabled {
+		if err := a.api.Close(); err != nil {
+			log.Printf("Error stopping API: %v", err)
+		}
+		return
+	}
+
+	if cfg.Token == "" {
+		token, err := api.NewToken()
+		if err != nil {
+			log.Printf("Failed to start API: %v", err)
+			return
+		}
+		cfg.Token = token
+		if err := a.config.Save(); err != nil {
+			log.Printf("Failed to save API token: %v", err)
+		}
+	}
+	a.api.SetToken(cfg.Token)
+
+	if a.api.Addr() == cfg.Address() {
+		return
+	}
+	if err := a.api.Listen(cfg.Address()); err != nil {
+		log.Printf("Failed to start API: %v", err)
+	}
+}
+
+// GetAPIAddress returns the base URL of the REST API, or "" when it is not
+// serving
+func (a *App) GetAPIAddress() string {
+	if addr := a.api.Addr(); addr != "" {
+		return "http://" + addr + "/api"
+	}
+	return ""
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"aniki/internal/backup"
+	"aniki/internal/database"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// backupCheckInterval is how often the scheduler checks whether a backup
+// is due. Due-ness is derived from the newest backup file, so the schedule
+// survives restarts.
+const backupCheckInterval = 10 * time.Minute
+
+// MaintenanceReport summarises a maintenance run
+type MaintenanceReport struct {
+	Problems   []string `json:"problems"` // integrity check failures
+	Compacted  bool     `json:"compacted"`
+	SizeBefore int64    `json:"size_before"`
+	SizeAfter  int64    `json:"size_after"`
+	DurationMs int64    `json:"duration_ms"`
+}
+
+// backupManager returns a backup manager for the configured directory.
+// The caller must hold a.mu.
+func (a *App) backupManager() (*backup.Manager, error) {
+	dir, err := a.config.ResolveBackupDir()
+	if err != nil {
+		return nil, err
+	}
+	return backup.NewManager(dir, a.config.Backup.Keep), nil
+}
+
+// BackupDatabase takes an immediate backup of the active database
+func (a *App) BackupDatabase() (*backup.Info, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	m, err := a.backupManager()
+	if err != nil {
+		return nil, err
+	}
+	return m.Create(a.db, a.dbPath)
+}
+
+// ListBackups returns the backups of the active database, newest first
+func (a *App) ListBackups() ([]backup.Info, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	m, err := a.backupManager()
+	if err != nil {
+		return nil, err
+	}
+	return m.List(a.dbPath)
+}
+
+// SelectBackupFile opens a file picker for a backup to restore
+func (a *App) SelectBackupFile() (string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	dir, _ := a.config.ResolveBackupDir()
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:            "Select Backup",
+		DefaultDirectory: dir,
+		Filters: []runtime.FileFilter{
+			{DisplayName: "SQLite Databases (*.db, *.bak)", Pattern: "*.db;*.bak"},
+		},
+	})
+}
+
+// RestoreBackup replaces the active database with the backup at
+// backupPath after validating it. The current database is saved next to
+// the database file first so the restore can be undone.
+func (a *App) RestoreBackup(backupPath string) error {
+	if _, err := database.ValidateFile(backupPath); err != nil {
+		return fmt.Errorf("invalid backup %s: %w", backupPath, err)
+	}
+
+	a.mu.Lock()
+	dbPath := a.dbPath
+	err := a.restoreBackup(backupPath)
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	runtime.EventsEmit(a.ctx, EventDatabaseSwitched, dbPath)
+	return nil
+}
+
+// restoreBackup swaps the database file for backupPath and reopens it.
+// The caller must hold a.mu for writing.
+func (a *App) restoreBackup(backupPath string) error {
+	dbPath := a.dbPath
+
+	previous := fmt.Sprintf("%s.pre-restore-%s.bak", dbPath, time.Now().Format("20060102-150405"))
+	if err := a.db.BackupTo(previous); err != nil {
+		return fmt.Errorf("failed to save current database: %w", err)
+	}
+
+	a.closeDatabase(a.ctx)
+
+	if err := database.ReplaceFile(dbPath, backupPath); err != nil {
+		if reopenErr := a.openDatabase(dbPath); reopenErr != nil {
+			log.Printf("Failed to reopen database after failed restore: %v", reopenErr)
+		}
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	if err := a.openDatabase(dbPath); err != nil {
+		// Put the previous database back
+		if rollbackErr := database.ReplaceFile(dbPath, previous); rollbackErr != nil {
+			log.Printf("Failed to roll back restore, previous database is at %s: %v", previous, rollbackErr)
+		} else if reopenErr := a.openDatabase(dbPath); reopenErr != nil {
+			log.Printf("Failed to reopen database after rollback: %v", reopenErr)
+		}
+		return fmt.Errorf("failed to open restored database: %w", err)
+	}
+
+	log.Printf("Restored %s from %s (previous database saved to %s)", dbPath, backupPath, previous)
+	return nil
+}
+
+// RunMaintenance checks the integrity of the active database and, if it is
+// intact, compacts it
+func (a *App) RunMaintenance() (*MaintenanceReport, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	start := time.Now()
+	report := &MaintenanceReport{SizeBefore: databaseSize(a.dbPath)}
+
+	problems, err := a.db.IntegrityCheck()
+	if err != nil {
+		return nil, err
+	}
+	report.Problems = problems
+
+	if len(problems) == 0 {
+		if err := a.db.Compact(); err != nil {
+			return nil, err
+		}
+		report.Compacted = true
+	} else {
+		log.Printf("Integrity check of %s found %d problems, skipping compaction", a.dbPath, len(problems))
+	}
+
+	report.SizeAfter = databaseSize(a.dbPath)
+	report.DurationMs = time.Since(start).Milliseconds()
+	return report, nil
+}
+
+// startBackupSchedule (re)starts scheduled backups according to the
+// configuration. The caller must hold a.mu for writing.
+func (a *App) startBackupSchedule() {
+	a.stopBackupSchedule()
+
+	cfg := a.config.Backup
+	if !cfg.Enabled || cfg.IntervalHours <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.backupCancel = cancel
+	go a.runBackupSchedule(ctx, time.Duration(cfg.IntervalHours)*time.Hour)
+}
+
+// stopBackupSchedule stops scheduled backups. The caller must hold a.mu
+// for writing.
+func (a *App) stopBackupSchedule() {
+	if a.backupCancel != nil {
+		a.backupCancel()
+		a.backupCancel = nil
+	}
+}
+
+// runBackupSchedule backs up the active database whenever its newest
+// backup is older than interval, until ctx is cancelled
+func (a *App) runBackupSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		a.backupIfDue(interval)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// backupIfDue takes a scheduled backup if the newest one is too old
+func (a *App) backupIfDue(interval time.Duration) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.db == nil {
+		return
+	}
+
+	m, err := a.backupManager()
+	if err != nil {
+		log.Printf("Scheduled backup failed: %v", err)
+		return
+	}
+
+	latest, err := m.Latest(a.dbPath)
+	if err != nil {
+		log.Printf("Scheduled backup failed: %v", err)
+		return
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < interval {
+		return
+	}
+
+	info, err := m.Create(a.db, a.dbPath)
+	if err != nil {
+		log.Printf("Scheduled backup failed: %v", err)
+		return
+	}
+	log.Printf("Scheduled backup written to %s", info.Path)
+}
+
+// databaseSize returns the size of the database file including its
+// write-ahead log
+func databaseSize(dbPath string) int64 {
+	var size int64
+	for _, path := range []string{dbPath, dbPath + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
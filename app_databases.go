@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aniki/internal/config"
+	"aniki/internal/database"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// DatabaseInfo describes a database registered in the configuration
+type DatabaseInfo struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Active bool   `json:"active"`
+	Exists bool   `json:"exists"`
+}
+
+// ListDatabases returns all registered databases, marking the active one
+func (a *App) ListDatabases() []DatabaseInfo {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	infos := make([]DatabaseInfo, 0, len(a.config.Databases))
+	for _, db := range a.config.Databases {
+		_, err := os.Stat(db.Path)
+		infos = append(infos, DatabaseInfo{
+			Name:   db.Name,
+			Path:   db.Path,
+			Active: db.Path == a.dbPath,
+			Exists: err == nil,
+		})
+	}
+	return infos
+}
+
+// CreateDatabase creates an empty database named name in the configuration
+// directory and registers it. It does not switch to the new database.
+func (a *App) CreateDatabase(name string) (*DatabaseInfo, error) {
+	name = strings.TrimSpace(name)
+	dbPath, err := config.NewDatabasePath(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		return nil, fmt.Errorf("database file already exists: %s", dbPath)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	db, err := database.New(dbPath, a.databaseOptions())
+	if err != nil {
+		return nil, err
+	}
+	db.Close()
+
+	a.config.AddDatabase(name, dbPath)
+	if err := a.config.Save(); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+
+	return &DatabaseInfo{Name: name, Path: dbPath, Exists: true}, nil
+}
+
+// OpenDatabase registers an existing database file and switches to it
+func (a *App) OpenDatabase(dbPath string) error {
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("database file not found: %w", err)
+	}
+
+	a.mu.Lock()
+	if _, ok := a.config.FindDatabase(dbPath); !ok {
+		name := strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+		a.config.AddDatabase(name, dbPath)
+	}
+	a.mu.Unlock()
+
+	return a.SwitchDatabase(dbPath)
+}
+
+// SwitchDatabase makes the registered database at dbPath the active one
+// without restarting. The watcher is rebound to the new database.
+func (a *App) SwitchDatabase(dbPath string) error {
+	a.mu.Lock()
+
+	if dbPath == a.dbPath {
+		a.mu.Unlock()
+		return nil
+	}
+	if _, ok := a.config.FindDatabase(dbPath); !ok {
+		a.mu.Unlock()
+		return fmt.Errorf("unknown database: %s", dbPath)
+	}
+
+	if err := a.openDatabase(dbPath); err != nil {
+		a.mu.Unlock()
+		return fmt.Errorf("failed to switch database: %w", err)
+	}
+
+	a.config.DatabasePath = dbPath
+	err := a.config.Save()
+	a.mu.Unlock()
+
+	runtime.EventsEmit(a.ctx, EventDatabaseSwitched, dbPath)
+
+	if err != nil {
+		return fmt.Errorf("switched database but failed to save config: %w", err)
+	}
+	return nil
+}
+
+// RemoveDatabase unregisters a database. The active database cannot be
+// removed, and the file itself is never deleted.
+func (a *App) RemoveDatabase(dbPath string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if dbPath == a.dbPath {
+		return fmt.Errorf("cannot remove the active database")
+	}
+
+	a.config.RemoveDatabase(dbPath)
+	if err := a.config.Save(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+// SelectDatabaseFile opens a file picker for an existing database
+func (a *App) SelectDatabaseFile() (string, error) {
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Open Database",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "SQLite Databases (*.db)", Pattern: "*.db"},
+		},
+	})
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"aniki/internal/database"
+	"aniki/internal/export"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// exportBatchSize is the number of hands loaded at a time while exporting
+const exportBatchSize = 500
+
+// GetExportFormats returns the formats hands can be exported in
+func (a *App) GetExportFormats() []export.Format {
+	return export.Formats()
+}
+
+// ExportHands writes the hands matching filter in format to a file chosen
+// in a save dialog. The result is nil when the dialog is cancelled.
+func (a *App) ExportHands(filter database.HandFilter, format string) (*export.Result, error) {
+	f, err := export.Lookup(format)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Hands",
+		DefaultFilename: "aniki-hands" + f.Extension,
+		Filters: []runtime.FileFilter{
+			{DisplayName: f.Description, Pattern: "*" + f.Extension},
+		},
+	})
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	count, err := a.exportHands(path, filter, f)
+	if err != nil {
+		return nil, err
+	}
+	return &export.Result{Path: path, Rows: count}, nil
+}
+
+// exportHands streams the hands matching filter to path, removing the file
+// if the export fails
+func (a *App) exportHands(path string, filter database.HandFilter, format export.Format) (int, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	count, err := a.writeHands(file, filter, format)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write export file: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+	return count, nil
+}
+
+// writeHands writes the hands matching filter to w in format and returns
+// how many were written
+func (a *App) writeHands(w io.Writer, filter database.HandFilter, format export.Format) (int, error) {
+	count := 0
+	writer := format.NewWriter(w)
+
+	a.mu.RLock()
+	err := a.handRepo.ForEach(filter, exportBatchSize, func(hands []database.Hand) error {
+		for i := range hands {
+			if err := writer.WriteHand(&hands[i]); err != nil {
+				return fmt.Errorf("failed to write hand %s: %w", hands[i].HandID, err)
+			}
+			count++
+		}
+		return nil
+	})
+	a.mu.RUnlock()
+
+	if err != nil {
+		return 0, err
+	}
+	return count, writer.Flush()
+}
+
+// statsBySession groups exported statistics by session instead of one of
+// the groupings of HandRepository.GetGroupStats
+const statsBySession = "session"
+
+// ExportStats writes the statistics of the hands matching filter, grouped
+// by position, stakes, opponent or session, as CSV to a file chosen in a
+// save dialog. Sessions need the filter's hero and keep only its date
+// range. The result is nil when the dialog is cancelled.
+func (a *App) ExportStats(filter database.HandFilter, group string) (*export.Result, error) {
+	var write func(w io.Writer) (int, error)
+
+	switch group {
+	case statsBySession:
+		if filter.HeroName == "" {
+			return nil, fmt.Errorf("a hero is needed to export sessions")
+		}
+		write = func(w io.Writer) (int, error) {
+			a.mu.RLock()
+			sessions, err := a.sessionRepo.FindAll(filter.HeroName, a.config.Sessions.Gap())
+			a.mu.RUnlock()
+			if err != nil {
+				return 0, err
+			}
+			sessions = sessionsBetween(sessions, filter.DateFrom, filter.DateTo)
+			return len(sessions), export.WriteSessionsCSV(w, sessions)
+		}
+	case database.GroupPosition, database.GroupStakes, database.GroupOpponent:
+		write = func(w io.Writer) (int, error) {
+			a.mu.RLock()
+			stats, err := a.handRepo.GetGroupStats(filter, group)
+			a.mu.RUnlock()
+			if err != nil {
+				return 0, err
+			}
+			return len(stats), export.WriteGroupStatsCSV(w, group, stats)
+		}
+	default:
+		return nil, fmt.Errorf("unknown grouping %q", group)
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Statistics",
+		DefaultFilename: "aniki-stats-by-" + group + ".csv",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "CSV Files (*.csv)", Pattern: "*.csv"},
+		},
+	})
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	count, err := write(file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to export statistics: %w", err)
+	}
+	return &export.Result{Path: path, Rows: count}, nil
+}
+
+// sessionsBetween keeps the sessions starting within the optional range
+func sessionsBetween(sessions []database.Session, from, to *time.Time) []database.Session {
+	kept := sessions[:0]
+	for _, s := range sessions {
+		if (from == nil || !s.Start.Before(*from)) && (to == nil || !s.Start.After(*to)) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// ShareHand summarizes a hand for posting with screen names replaced by
+// positions, as plain text, Markdown and a PNG image
+func (a *App) ShareHand(id int64, opts export.ShareOptions) (*export.SharedHand, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	hand, err := a.handRepo.FindByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hand: %w", err)
+	}
+	if hand == nil {
+		return nil, fmt.Errorf("hand %d not found", id)
+	}
+	return export.Share(hand, opts)
+}
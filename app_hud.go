@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+
+	"aniki/internal/hud"
+	"aniki/internal/watcher"
+)
+
+// EventHUDUpdated is emitted with a hud.Table payload whenever a new hand
+// of a table is imported
+const EventHUDUpdated = "hud:updated"
+
+// updateHUD publishes the HUD of the tables the watcher just imported
+// hands for. It runs on watcher workers, which holders of a.mu wait for
+// when stopping the watcher, so it must not take a.mu.
+func (a *App) updateHUD(event watcher.ImportEvent, stats hud.StatsSource) {
+	if len(event.Latest) == 0 {
+		return
+	}
+	if err := a.hud.Update(stats, event.Site, event.Latest); err != nil {
+		log.Printf("Failed to update HUD: %v", err)
+	}
+}
+
+// applyHUDConfig starts or stops the HUD to match the configuration. The
+// caller must hold a.mu for writing.
+func (a *App) applyHUDConfig() {
+	cfg := a.config.HUD
+	a.hud.SetEnabled(cfg.Enabled)
+
+	if !cfg.Enabled {
+		if err := a.hud.Close(); err != nil {
+			log.Printf("Error stopping HUD: %v", err)
+		}
+		return
+	}
+	if a.hud.Addr() == cfg.Address() {
+		return
+	}
+	if err := a.hud.Listen(cfg.Address()); err != nil {
+		log.Printf("Failed to start HUD: %v", err)
+	}
+}
+
+// GetHUDTables returns the HUD of every table played recently
+func (a *App) GetHUDTables() []hud.Table {
+	return a.hud.Tables()
+}
+
+// GetHUDAddress returns the WebSocket URL overlays connect to, or "" when
+// the HUD is not serving
+func (a *App) GetHUDAddress() string {
+	if addr := a.hud.Addr(); addr != "" {
+		return "ws://" + addr + "/hud"
+	}
+	return ""
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"aniki/internal/database"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// GetAnnotations returns the notes, tags and color label of an opponent
+// or hand
+func (a *App) GetAnnotations(target database.Target) (*database.Annotations, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.Find(target)
+}
+
+// AddNote attaches a note to an opponent or hand
+func (a *App) AddNote(target database.Target, text string) (*database.Note, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.AddNote(target, text)
+}
+
+// UpdateNote replaces the text of a note
+func (a *App) UpdateNote(id int64, text string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.UpdateNote(id, text)
+}
+
+// DeleteNote removes a note
+func (a *App) DeleteNote(id int64) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.DeleteNote(id)
+}
+
+// SearchNotes returns notes containing text, newest first
+func (a *App) SearchNotes(text string, limit int) ([]database.Note, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.SearchNotes(text, limit)
+}
+
+// AddTag tags an opponent or hand
+func (a *App) AddTag(target database.Target, name string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.AddTag(target, name)
+}
+
+// RemoveTag removes a tag from an opponent or hand
+func (a *App) RemoveTag(target database.Target, name string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.RemoveTag(target, name)
+}
+
+// GetTagNames returns every tag name in use
+func (a *App) GetTagNames() ([]string, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.TagNames()
+}
+
+// SetColorLabel marks an opponent or hand with a color; an empty color
+// removes the label
+func (a *App) SetColorLabel(target database.Target, color string) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.SetColor(target, color)
+}
+
+// SelectNotesExportFile opens a save dialog for a notes export
+func (a *App) SelectNotesExportFile() (string, error) {
+	return runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Notes",
+		DefaultFilename: "aniki-notes.json",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "JSON Files (*.json)", Pattern: "*.json"},
+		},
+	})
+}
+
+// SelectNotesImportFile opens a file picker for a notes export to import
+func (a *App) SelectNotesImportFile() (string, error) {
+	return runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Import Notes",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "JSON Files (*.json)", Pattern: "*.json"},
+		},
+	})
+}
+
+// ExportNotes writes every note, tag and color label to path as JSON
+func (a *App) ExportNotes(path string) error {
+	a.mu.RLock()
+	export, err := a.noteRepo.Export()
+	a.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode notes: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write notes: %w", err)
+	}
+	return nil
+}
+
+// ImportNotes adds the notes, tags and color labels exported to path
+func (a *App) ImportNotes(path string) (*database.NotesImport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes: %w", err)
+	}
+	var export database.NotesExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid notes file %s: %w", path, err)
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.noteRepo.Import(&export)
+}
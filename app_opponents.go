@@ -0,0 +1,40 @@
+package main
+
+import (
+	"aniki/internal/database"
+)
+
+// GetOpponents returns opponents whose name contains name, most recently
+// seen first
+func (a *App) GetOpponents(name string, limit, offset int) ([]database.Opponent, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.opponentRepo.FindAll(name, limit, offset)
+}
+
+// GetOpponentStats returns an opponent's profile: aliases, preflop stats,
+// showdowns and the hero's result against them, or nil for an unknown id
+func (a *App) GetOpponentStats(id int64) (*database.OpponentStats, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.opponentRepo.GetStats(id)
+}
+
+// GetOpponentHands returns a page of the hands played against an opponent
+func (a *App) GetOpponentHands(id int64, limit, offset int) (*database.HandPage, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.opponentRepo.FindHands(id, limit, offset)
+}
+
+// MergeOpponents merges the aliases into the target opponent, e.g. when
+// one player uses several screen names
+func (a *App) MergeOpponents(targetID int64, aliasIDs []int64) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.opponentRepo.Merge(targetID, aliasIDs)
+}
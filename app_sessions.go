@@ -0,0 +1,26 @@
+package main
+
+import (
+	"aniki/internal/database"
+)
+
+// GetSessions returns the hero's sessions, newest first, split wherever
+// the break between hands exceeds the configured session gap
+func (a *App) GetSessions(heroName string) ([]database.Session, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.sessionRepo.FindAll(heroName, a.config.Sessions.Gap())
+}
+
+// GetSessionHands returns the hands played in a session
+func (a *App) GetSessionHands(session database.Session) ([]database.Hand, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.handRepo.FindAll(database.HandFilter{
+		HeroName: session.HeroName,
+		DateFrom: &session.Start,
+		DateTo:   &session.End,
+	})
+}
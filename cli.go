@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"aniki/internal/config"
+	"aniki/internal/database"
+	"aniki/internal/export"
+	"aniki/internal/hand_history"
+	"aniki/internal/watcher"
+)
+
+// cliUsage describes the headless commands
+const cliUsage = `Usage: aniki [command] [flags]
+
+Without a command, aniki opens the tracker window. Commands run headless
+against the configured database:
+
+  import   Import hand history files or directories once
+  stats    Print statistics of the hero's hands
+  export   Write hands in an export format
+  watch    Import new hands from watched folders until interrupted
+
+Run "aniki <command> -h" for the flags of a command.
+`
+
+// cliCommands are the headless commands by name
+var cliCommands = map[string]func(args []string) error{
+	"import": runImport,
+	"stats":  runStats,
+	"export": runExport,
+	"watch":  runWatch,
+}
+
+// isCLICommand reports whether arg selects a headless command rather than
+// the window
+func isCLICommand(arg string) bool {
+	switch arg {
+	case "help", "-h", "-help", "--help":
+		return true
+	}
+	_, ok := cliCommands[arg]
+	return ok
+}
+
+// runCLI runs a headless command and returns the process exit code
+func runCLI(name string, args []string) int {
+	run, ok := cliCommands[name]
+	if !ok {
+		fmt.Print(cliUsage)
+		return 0
+	}
+	if err := run(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "aniki %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// cliOptions are the flags shared by all commands
+type cliOptions struct {
+	dbPath  string
+	verbose bool
+}
+
+// newFlagSet creates the flags of a command along with the shared ones
+func newFlagSet(name, usage string) (*flag.FlagSet, *cliOptions) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: aniki %s %s\n\nFlags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	opts := &cliOptions{}
+	fs.StringVar(&opts.dbPath, "db", "", "database file (default: the active database)")
+	fs.BoolVar(&opts.verbose, "v", false, "log progress to stderr")
+	return fs, opts
+}
+
+// filterFlags adds the hand filter flags to fs and returns a function
+// building the filter once the flags are parsed
+func filterFlags(fs *flag.FlagSet) func(cfg *config.Config) (database.HandFilter, error) {
+	hero := fs.String("hero", "", "hero screen name (default: the configured hero)")
+	game := fs.String("game", "", `game type, e.g. "Hold'em No Limit"`)
+	since := fs.String("since", "", "first day, as YYYY-MM-DD")
+	until := fs.String("until", "", "last day, as YYYY-MM-DD")
+	tags := fs.String("tags", "", "comma separated tags, keeping hands with any of them")
+
+	return func(cfg *config.Config) (database.HandFilter, error) {
+		filter := database.HandFilter{HeroName: *hero, GameType: *game}
+		if filter.HeroName == "" {
+			filter.HeroName = cfg.HeroName
+		}
+		var err error
+		if filter.DateFrom, err = parseDay(*since, false); err != nil {
+			return filter, fmt.Errorf("invalid -since: %w", err)
+		}
+		if filter.DateTo, err = parseDay(*until, true); err != nil {
+			return filter, fmt.Errorf("invalid -until: %w", err)
+		}
+		for _, tag := range strings.Split(*tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+		return filter, nil
+	}
+}
+
+// parseDay parses a YYYY-MM-DD day in local time, returning its last
+// moment when end is set. Empty values are no limit.
+func parseDay(value string, end bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if end {
+		day = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return &day, nil
+}
+
+// openHeadless loads the configuration and opens a database with its
+// repositories, without the window, file watcher or HUD
+func openHeadless(opts *cliOptions) (*App, error) {
+	if !opts.verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	a := NewApp()
+	a.ctx = context.Background()
+	a.config = cfg
+	a.parser = hand_history.NewManager()
+
+	dbPath := opts.dbPath
+	if dbPath == "" {
+		if dbPath, err = cfg.ResolveDatabasePath(); err != nil {
+			return nil, fmt.Errorf("failed to get database path: %w", err)
+		}
+	}
+	db, err := database.New(dbPath, a.databaseOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", dbPath, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.db = db
+	a.dbPath = dbPath
+	a.setRepositories(db)
+	a.initializeSites()
+	return a, nil
+}
+
+// closeHeadless drains the watcher, if any, and closes the database
+func (a *App) closeHeadless() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closeDatabase(a.ctx)
+}
+
+// newHeadlessWatcher creates a file watcher reporting imports to onImport
+func (a *App) newHeadlessWatcher(onImport func(watcher.ImportEvent)) (*watcher.Watcher, error) {
+	w, err := watcher.New(a.parser, a.siteRepo, a.handRepo, a.playerRepo, a.actionRepo, watcher.Options{
+		Workers:       a.config.Watcher.Workers,
+		QueueSize:     a.config.Watcher.QueueSize,
+		DebounceDelay: a.config.Watcher.Debounce(),
+		OnImport:      onImport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize watcher: %w", err)
+	}
+	return w, nil
+}
+
+// printImport writes the outcome of importing a file
+func printImport(event watcher.ImportEvent) {
+	line := fmt.Sprintf("%s: %d saved, %d skipped", event.File, event.Saved, event.Skipped)
+	if event.Failed > 0 {
+		line += fmt.Sprintf(", %d failed", event.Failed)
+	}
+	if event.Error != "" {
+		line += " (" + event.Error + ")"
+	}
+	fmt.Println(line)
+}
+
+// runImport imports files and directories once
+func runImport(args []string) error {
+	fs, opts := newFlagSet("import", "[flags] <file or directory>...")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no files or directories to import")
+	}
+
+	a, err := openHeadless(opts)
+	if err != nil {
+		return err
+	}
+	defer a.closeHeadless()
+
+	var files, saved, skipped, failed, errored int
+	w, err := a.newHeadlessWatcher(func(event watcher.ImportEvent) {
+		printImport(event)
+		files++
+		saved += event.Saved
+		skipped += event.Skipped
+		failed += event.Failed
+		if event.Error != "" {
+			errored++
+		}
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := w.Stop(stopCtx); err != nil {
+			log.Printf("Watcher did not stop cleanly: %v", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for _, path := range fs.Args() {
+		if err := w.ImportPath(ctx, path, watcher.PathOptions{}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Imported %d hands from %d files, %d duplicates skipped, %d failed\n", saved, files, skipped, failed)
+	if errored > 0 {
+		return fmt.Errorf("%d files could not be imported", errored)
+	}
+	return nil
+}
+
+// runStats prints the hero's statistics, in total or grouped
+func runStats(args []string) error {
+	fs, opts := newFlagSet("stats", "[flags]")
+	buildFilter := filterFlags(fs)
+	by := fs.String("by", database.GroupTotal, "grouping: total, position, stakes, opponent or session")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := openHeadless(opts)
+	if err != nil {
+		return err
+	}
+	defer a.closeHeadless()
+
+	filter, err := buildFilter(a.config)
+	if err != nil {
+		return err
+	}
+	if filter.HeroName == "" {
+		return fmt.Errorf("no hero: pass -hero or configure one")
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	defer out.Flush()
+
+	if *by == statsBySession {
+		sessions, err := a.sessionRepo.FindAll(filter.HeroName, a.config.Sessions.Gap())
+		if err != nil {
+			return err
+		}
+		sessions = sessionsBetween(sessions, filter.DateFrom, filter.DateTo)
+		if *asJSON {
+			return printJSON(sessions)
+		}
+		fmt.Fprintln(out, "Start\tMinutes\tHands\tNet\tEV\tRake\tBB/100\t")
+		for _, s := range sessions {
+			fmt.Fprintf(out, "%s\t%.0f\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t\n", s.Start.Format("2006-01-02 15:04"), s.DurationMinutes, s.Hands, s.NetWon, s.EVWon, s.Rake, s.BBPer100)
+		}
+		return nil
+	}
+
+	stats, err := a.handRepo.GetGroupStats(filter, *by)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(stats)
+	}
+	fmt.Fprintf(out, "%s\tHands\tNet\tEV\tRake\tBB/100\tVPIP\tPFR\t\n", strings.ToUpper((*by)[:1])+(*by)[1:])
+	for _, s := range stats {
+		fmt.Fprintf(out, "%s\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.1f\t%.1f\t\n", s.Group, s.Hands, s.NetWon, s.EVWon, s.Rake, s.BBPer100, s.VPIP, s.PFR)
+	}
+	return nil
+}
+
+// printJSON writes v as indented JSON to stdout
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// runExport writes the matching hands in an export format
+func runExport(args []string) error {
+	var names []string
+	for _, format := range export.Formats() {
+		names = append(names, format.Name)
+	}
+
+	fs, opts := newFlagSet("export", "[flags]")
+	buildFilter := filterFlags(fs)
+	formatName := fs.String("format", export.FormatOHH, "export format: "+strings.Join(names, ", "))
+	output := fs.String("o", "", "output file (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	format, err := export.Lookup(*formatName)
+	if err != nil {
+		return err
+	}
+
+	a, err := openHeadless(opts)
+	if err != nil {
+		return err
+	}
+	defer a.closeHeadless()
+
+	filter, err := buildFilter(a.config)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err := a.writeHands(os.Stdout, filter, format)
+		return err
+	}
+	count, err := a.exportHands(*output, filter, format)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d hands to %s\n", count, *output)
+	return nil
+}
+
+// runWatch imports new hands from the given directories, or the enabled
+// sites' folders, until interrupted
+func runWatch(args []string) error {
+	fs, opts := newFlagSet("watch", "[flags] [directory...]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	a, err := openHeadless(opts)
+	if err != nil {
+		return err
+	}
+	defer a.closeHeadless()
+
+	w, err := a.newHeadlessWatcher(printImport)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.watcher = w
+	a.mu.Unlock()
+
+	watching := 0
+	if fs.NArg() > 0 {
+		for _, path := range fs.Args() {
+			if err := w.AddPath(path, watcher.PathOptions{}); err != nil {
+				return err
+			}
+			watching++
+		}
+	} else {
+		for _, site := range a.config.Sites {
+			if !site.Enabled || site.WatchPath == "" {
+				continue
+			}
+			if err := w.AddPath(site.WatchPath, watchOptions(site)); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to watch %s: %v\n", site.WatchPath, err)
+				continue
+			}
+			watching++
+		}
+	}
+	if watching == 0 {
+		return fmt.Errorf("nothing to watch: pass directories or enable a site's watch path")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w.Start()
+	fmt.Fprintf(os.Stderr, "Watching %d folders, press Ctrl+C to stop\n", watching)
+	<-ctx.Done()
+	fmt.Fprintln(os.Stderr, "Stopping, importing queued files...")
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package api serves the tracker database as a JSON REST API on localhost,
+// for scripts, bots and spreadsheets to query while the app is running.
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"aniki/internal/database"
+	"aniki/internal/watcher"
+)
+
+// Source is the tracker data served by the API. It must be safe for
+// concurrent use.
+type Source interface {
+	SearchHands(query string, limit, offset int) (*database.HandPage, error)
+	GetHandByID(id int64) (*database.Hand, error)
+	GetGroupStats(filter database.HandFilter, group string) ([]database.GroupStats, error)
+	GetSessions(heroName string) ([]database.Session, error)
+	GetOpponents(name string, limit, offset int) ([]database.Opponent, error)
+	GetOpponentStats(id int64) (*database.OpponentStats, error)
+	GetWatcherStatus() watcher.Status
+}
+
+// Server serves a Source over HTTP to clients presenting its token
+type Server struct {
+	source   Source
+	mu       sync.Mutex
+	token    string
+	http     *http.Server
+	listener net.Listener
+}
+
+// NewServer creates a server for source. It does not listen until Listen
+// is called.
+func NewServer(source Source) *Server {
+	return &Server{source: source}
+}
+
+// NewToken returns a random token for clients to authenticate with
+func NewToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SetToken changes the token clients must present. Requests are refused
+// while it is empty.
+func (s *Server) SetToken(token string) {
+	s.mu.Lock()
+	s.token = token
+	s.mu.Unlock()
+}
+
+// Listen serves the API at http://addr/api, replacing any server already
+// running. addr should be a loopback address.
+func (s *Server) Listen(addr string) error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	srv := &http.Server{Handler: s.authenticate(s.routes()), ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("API server stopped: %v", err)
+		}
+	}()
+
+	s.mu.Lock()
+	s.http = srv
+	s.listener = listener
+	s.mu.Unlock()
+
+	log.Printf("API listening on http://%s/api", listener.Addr())
+	return nil
+}
+
+// Addr returns the address the API listens on, or "" when it is not
+// running
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops the server. Requests in progress are cut off rather than
+// waited for, as they may be waiting on the caller.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	srv := s.http
+	s.http = nil
+	s.listener = nil
+	s.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+	if err := srv.Close(); err != nil {
+		return fmt.Errorf("failed to stop API server: %w", err)
+	}
+	return nil
+}
+
+// authenticate refuses requests without the server's token, sent as
+// "Authorization: Bearer <token>" or, for tools that cannot set headers,
+// a token query parameter
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		token := s.token
+		s.mu.Unlock()
+
+		given := r.URL.Query().Get("token")
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			// Other schemes never match
+			var ok bool
+			if given, ok = strings.CutPrefix(auth, "Bearer "); !ok {
+				given = ""
+			}
+		}
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="aniki"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
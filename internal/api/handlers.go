@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"aniki/internal/database"
+	"aniki/internal/search"
+)
+
+const (
+	// defaultLimit is the page size when a request sets none
+	defaultLimit = 50
+	// maxLimit bounds the page size a request may ask for
+	maxLimit = 500
+)
+
+// routes maps the API endpoints to their handlers
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/hands", s.hands)
+	mux.HandleFunc("GET /api/hands/{id}", s.hand)
+	mux.HandleFunc("GET /api/stats", s.stats)
+	mux.HandleFunc("GET /api/sessions", s.sessions)
+	mux.HandleFunc("GET /api/players", s.players)
+	mux.HandleFunc("GET /api/players/{id}", s.player)
+	mux.HandleFunc("GET /api/import/status", s.importStatus)
+	return mux
+}
+
+// hands returns a page of hands matching the search query q, newest first
+func (s *Server) hands(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := page(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result, err := s.source.SearchHands(r.URL.Query().Get("q"), limit, offset)
+	if errors.Is(err, search.ErrSyntax) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, result)
+}
+
+// hand returns a hand with its players and actions
+func (s *Server) hand(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid hand id")
+		return
+	}
+	hand, err := s.source.GetHandByID(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if hand == nil {
+		writeError(w, http.StatusNotFound, "hand not found")
+		return
+	}
+	writeJSON(w, hand)
+}
+
+// stats returns the statistics of the hands matching the filter
+// parameters, in total or grouped by position, stakes or opponent
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	filter, err := handFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	group := r.URL.Query().Get("by")
+	if group == "" {
+		group = database.GroupTotal
+	}
+	stats, err := s.source.GetGroupStats(filter, group)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, stats)
+}
+
+// sessions returns the sessions of a hero, optionally starting within
+// since and until
+func (s *Server) sessions(w http.ResponseWriter, r *http.Request) {
+	filter, err := handFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.HeroName == "" {
+		writeError(w, http.StatusBadRequest, "hero is required")
+		return
+	}
+	sessions, err := s.source.GetSessions(filter.HeroName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	kept := make([]database.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if (filter.DateFrom == nil || !session.Start.Before(*filter.DateFrom)) &&
+			(filter.DateTo == nil || !session.Start.After(*filter.DateTo)) {
+			kept = append(kept, session)
+		}
+	}
+	writeJSON(w, kept)
+}
+
+// players returns a page of opponents whose name contains the name
+// parameter
+func (s *Server) players(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := page(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opponents, err := s.source.GetOpponents(r.URL.Query().Get("name"), limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, opponents)
+}
+
+// player returns an opponent's statistics
+func (s *Server) player(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid player id")
+		return
+	}
+	stats, err := s.source.GetOpponentStats(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if stats == nil {
+		writeError(w, http.StatusNotFound, "player not found")
+		return
+	}
+	writeJSON(w, stats)
+}
+
+// importStatus returns the watched folders and their import counters
+func (s *Server) importStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.source.GetWatcherStatus())
+}
+
+// page reads the limit and offset parameters
+func page(r *http.Request) (limit, offset int, err error) {
+	limit, offset = defaultLimit, 0
+	query := r.URL.Query()
+	if v := query.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("invalid limit %q", v)
+		}
+		limit = min(limit, maxLimit)
+	}
+	if v := query.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	return limit, offset, nil
+}
+
+// handFilter reads the hero, game, since, until and tags parameters.
+// Dates are days (YYYY-MM-DD, until inclusive) or RFC 3339 times.
+func handFilter(r *http.Request) (database.HandFilter, error) {
+	query := r.URL.Query()
+	filter := database.HandFilter{
+		HeroName: query.Get("hero"),
+		GameType: query.Get("game"),
+	}
+	var err error
+	if filter.DateFrom, err = parseTime(query.Get("since"), false); err != nil {
+		return filter, fmt.Errorf("invalid since: %w", err)
+	}
+	if filter.DateTo, err = parseTime(query.Get("until"), true); err != nil {
+		return filter, fmt.Errorf("invalid until: %w", err)
+	}
+	for _, tag := range strings.Split(query.Get("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+	return filter, nil
+}
+
+// parseTime parses an RFC 3339 time or a local day, returning the day's
+// last moment when end is set. Empty values are no limit.
+func parseTime(value string, end bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a date", value)
+	}
+	if end {
+		day = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return &day, nil
+}
+
+// writeJSON writes v as the JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response as {"error": message}
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
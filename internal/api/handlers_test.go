@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aniki/internal/database"
+	"aniki/internal/search"
+	"aniki/internal/watcher"
+)
+
+// fakeSource serves one opponent, id 1, and fails searches with err
+type fakeSource struct {
+	err error
+}
+
+func (f *fakeSource) SearchHands(query string, limit, offset int) (*database.HandPage, error) {
+	if _, err := search.Parse(query); err != nil {
+		return nil, err
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &database.HandPage{Hands: []database.Hand{}, Limit: limit, Offset: offset}, nil
+}
+
+func (f *fakeSource) GetHandByID(id int64) (*database.Hand, error) { return nil, nil }
+
+func (f *fakeSource) GetGroupStats(filter database.HandFilter, group string) ([]database.GroupStats, error) {
+	return nil, nil
+}
+
+func (f *fakeSource) GetSessions(heroName string) ([]database.Session, error) { return nil, nil }
+
+func (f *fakeSource) GetOpponents(name string, limit, offset int) ([]database.Opponent, error) {
+	return nil, nil
+}
+
+func (f *fakeSource) GetOpponentStats(id int64) (*database.OpponentStats, error) {
+	if id != 1 {
+		return nil, nil
+	}
+	return &database.OpponentStats{Opponent: database.Opponent{ID: 1, Name: "Villain"}}, nil
+}
+
+func (f *fakeSource) GetWatcherStatus() watcher.Status { return watcher.Status{} }
+
+// serve sends a request to a server for source with token set
+func serve(source Source, token string, r *http.Request) *httptest.ResponseRecorder {
+	s := NewServer(source)
+	s.SetToken(token)
+	w := httptest.NewRecorder()
+	s.authenticate(s.routes()).ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthenticate(t *testing.T) {
+	const token = "secret"
+	tests := []struct {
+		name   string
+		token  string // configured token
+		url    string
+		header string // Authorization header
+		want   int
+	}{
+		{"missing token", token, "/api/players/1", "", http.StatusUnauthorized},
+		{"wrong token", token, "/api/players/1", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong parameter", token, "/api/players/1?token=wrong", "", http.StatusUnauthorized},
+		{"token without Bearer", token, "/api/players/1", token, http.StatusUnauthorized},
+		{"Bearer header", token, "/api/players/1", "Bearer " + token, http.StatusOK},
+		{"token parameter", token, "/api/players/1?token=" + token, "", http.StatusOK},
+		{"header wins over parameter", token, "/api/players/1?token=" + token, "Bearer wrong", http.StatusUnauthorized},
+		{"no configured token", "", "/api/players/1", "Bearer ", http.StatusUnauthorized},
+		{"no configured token, empty parameter", "", "/api/players/1?token=", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		if got := serve(&fakeSource{}, tt.token, r).Code; got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandlerStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		source *fakeSource
+		url    string
+		want   int
+	}{
+		{"known player", &fakeSource{}, "/api/players/1", http.StatusOK},
+		{"unknown player", &fakeSource{}, "/api/players/2", http.StatusNotFound},
+		{"invalid player id", &fakeSource{}, "/api/players/x", http.StatusBadRequest},
+		{"search", &fakeSource{}, "/api/hands?q=pos:BTN", http.StatusOK},
+		{"invalid search", &fakeSource{}, "/api/hands?q=colour:red", http.StatusBadRequest},
+		{"database failure", &fakeSource{err: errors.New("disk I/O error")}, "/api/hands?q=pos:BTN", http.StatusInternalServerError},
+		{"invalid limit", &fakeSource{}, "/api/hands?limit=0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		if got := serve(tt.source, "secret", r).Code; got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"aniki/internal/database"
+)
+
+// timestampLayout is embedded in backup file names
+const timestampLayout = "20060102-150405"
+
+// Info describes a backup file
+type Info struct {
+	Path      string    `json:"path"`
+	Database  string    `json:"database"` // base name of the database it was taken from
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+
+	seq int // orders backups taken within the same second
+}
+
+// Manager creates, lists and rotates backups of databases in a directory.
+// Backups are named "<database>-<timestamp>.db" after the database file,
+// with a counter ("-2", "-3", ...) for further backups in the same second.
+type Manager struct {
+	dir  string
+	keep int
+}
+
+// NewManager creates a backup manager storing backups in dir and keeping
+// the newest keep backups per database. A keep of zero keeps all backups.
+func NewManager(dir string, keep int) *Manager {
+	return &Manager{dir: dir, keep: keep}
+}
+
+// Dir returns the backup directory
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Create takes an online, consistent backup of db, which is stored at
+// dbPath, and removes backups beyond the retention limit
+func (m *Manager) Create(db *database.DB, dbPath string) (*Info, error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	name := databaseName(dbPath)
+	now := time.Now()
+	base := fmt.Sprintf("%s-%s", name, now.Format(timestampLayout))
+	path := filepath.Join(m.dir, base+".db")
+	for seq := 2; ; seq++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		path = filepath.Join(m.dir, fmt.Sprintf("%s-%d.db", base, seq))
+	}
+
+	if err := db.BackupTo(path); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.Rotate(dbPath); err != nil {
+		return nil, err
+	}
+
+	return &Info{Path: path, Database: name, CreatedAt: now, Size: info.Size()}, nil
+}
+
+// List returns the backups of the database at dbPath, newest first
+func (m *Manager) List(dbPath string) ([]Info, error) {
+	name := databaseName(dbPath)
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `-(\d{8}-\d{6})(?:-(\d+))?\.db$`)
+
+	entries, err := os.ReadDir(m.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []Info
+	for _, entry := range entries {
+		matches := pattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+		createdAt, err := time.ParseInLocation(timestampLayout, matches[1], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		seq := 1
+		if matches[2] != "" {
+			seq, _ = strconv.Atoi(matches[2])
+		}
+		backups = append(backups, Info{
+			Path:      filepath.Join(m.dir, entry.Name()),
+			Database:  name,
+			CreatedAt: createdAt,
+			Size:      info.Size(),
+			seq:       seq,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	return backups, nil
+}
+
+// Latest returns the newest backup of the database at dbPath, or nil
+func (m *Manager) Latest(dbPath string) (*Info, error) {
+	backups, err := m.List(dbPath)
+	if err != nil || len(backups) == 0 {
+		return nil, err
+	}
+	return &backups[0], nil
+}
+
+// Rotate deletes the oldest backups of the database at dbPath beyond the
+// retention limit
+func (m *Manager) Rotate(dbPath string) error {
+	if m.keep <= 0 {
+		return nil
+	}
+
+	backups, err := m.List(dbPath)
+	if err != nil {
+		return err
+	}
+
+	for i := m.keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil {
+			return fmt.Errorf("failed to remove old backup %s: %w", backups[i].Path, err)
+		}
+	}
+	return nil
+}
+
+// databaseName returns the file name of dbPath without its extension
+func databaseName(dbPath string) string {
+	return strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+}
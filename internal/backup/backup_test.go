@@ -0,0 +1,40 @@
+package backup
+
+import (
+	"path/filepath"
+	"testing"
+
+	"aniki/internal/database"
+)
+
+func TestCreateTwiceInOneSecond(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "aniki.db")
+	db, err := database.New(dbPath, database.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := NewManager(filepath.Join(dir, "backups"), 0)
+	var created []string
+	for i := 0; i < 3; i++ {
+		info, err := m.Create(db, dbPath)
+		if err != nil {
+			t.Fatalf("backup %d: %v", i+1, err)
+		}
+		created = append(created, info.Path)
+	}
+
+	backups, err := m.List(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != len(created) {
+		t.Fatalf("listed %d backups, want %d", len(backups), len(created))
+	}
+	// Newest first, even within the same second
+	if backups[0].Path != created[len(created)-1] {
+		t.Errorf("newest backup is %s, want %s", backups[0].Path, created[len(created)-1])
+	}
+}
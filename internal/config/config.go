@@ -6,6 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
+	"unicode"
 )
 
 // Config holds the application configuration
@@ -13,7 +16,132 @@ type Config struct {
 	HeroName     string          `json:"hero_name"`
 	Sites        map[string]Site `json:"sites"`
 	DatabasePath string          `json:"database_path"`
+	Databases    []Database      `json:"databases"`
 	Theme        string          `json:"theme"`
+	Watcher      WatcherConfig   `json:"watcher"`
+	Database     DatabaseConfig  `json:"database"`
+	Backup       BackupConfig    `json:"backup"`
+	Sessions     SessionConfig   `json:"sessions"`
+	HUD          HUDConfig       `json:"hud"`
+	API          APIConfig       `json:"api"`
+}
+
+// DefaultSessionGapMinutes is the session gap used when none is configured
+const DefaultSessionGapMinutes = 30
+
+// SessionConfig controls how hands are grouped into sessions
+type SessionConfig struct {
+	// GapMinutes is the longest break between two hands of the same
+	// session. Zero selects DefaultSessionGapMinutes.
+	GapMinutes int `json:"gap_minutes"`
+}
+
+// Gap returns the configured session gap
+func (c SessionConfig) Gap() time.Duration {
+	if c.GapMinutes <= 0 {
+		return DefaultSessionGapMinutes * time.Minute
+	}
+	return time.Duration(c.GapMinutes) * time.Minute
+}
+
+// BackupConfig controls scheduled database backups
+type BackupConfig struct {
+	Enabled bool `json:"enabled"`
+	// IntervalHours is the minimum time between scheduled backups
+	IntervalHours int `json:"interval_hours"`
+	// Keep is the number of backups retained per database; zero keeps all
+	Keep int `json:"keep"`
+	// Directory holds the backups, defaulting to "backups" in the config
+	// directory
+	Directory string `json:"directory"`
+}
+
+// DatabaseConfig tunes the SQLite connection. Zero values select defaults.
+type DatabaseConfig struct {
+	// JournalMode is the SQLite journal mode, "WAL" unless set
+	JournalMode string `json:"journal_mode"`
+	// Synchronous is the SQLite synchronous setting, "NORMAL" unless set
+	Synchronous string `json:"synchronous"`
+	// BusyTimeoutMs is how long to wait for a lock before failing
+	BusyTimeoutMs int `json:"busy_timeout_ms"`
+	// MaxReaders is the size of the read-only connection pool
+	MaxReaders int `json:"max_readers"`
+	// DisableStatementCache turns off prepared statement caching
+	DisableStatementCache bool `json:"disable_statement_cache"`
+}
+
+// WatcherConfig tunes the hand history import pipeline
+type WatcherConfig struct {
+	// Workers is the number of files imported concurrently
+	Workers int `json:"workers"`
+	// QueueSize bounds the number of files waiting to be imported
+	QueueSize int `json:"queue_size"`
+	// DebounceMs is how long a file must stay unchanged before it is
+	// imported. Zero selects DefaultDebounceMs.
+	DebounceMs int `json:"debounce_ms"`
+}
+
+// DefaultDebounceMs is the watcher debounce used when none is configured.
+// It is short enough for the HUD to update within a second of a hand
+// being written.
+const DefaultDebounceMs = 250
+
+// Debounce returns the configured watcher debounce
+func (c WatcherConfig) Debounce() time.Duration {
+	if c.DebounceMs <= 0 {
+		return DefaultDebounceMs * time.Millisecond
+	}
+	return time.Duration(c.DebounceMs) * time.Millisecond
+}
+
+// DefaultHUDPort is the WebSocket port used when none is configured
+const DefaultHUDPort = 8765
+
+// HUDConfig controls the live HUD feed
+type HUDConfig struct {
+	// Enabled publishes per-seat stats after every import
+	Enabled bool `json:"enabled"`
+	// Port is the localhost port of the HUD WebSocket. Zero selects
+	// DefaultHUDPort.
+	Port int `json:"port"`
+}
+
+// Address returns the localhost address the HUD WebSocket listens on
+func (c HUDConfig) Address() string {
+	port := c.Port
+	if port <= 0 {
+		port = DefaultHUDPort
+	}
+	return fmt.Sprintf("127.0.0.1:%d", port)
+}
+
+// DefaultAPIPort is the REST API port used when none is configured
+const DefaultAPIPort = 8766
+
+// APIConfig controls the local REST API
+type APIConfig struct {
+	// Enabled serves the API on localhost while the app is running
+	Enabled bool `json:"enabled"`
+	// Port is the localhost port of the API. Zero selects DefaultAPIPort.
+	Port int `json:"port"`
+	// Token must be sent by clients as a bearer token. A random token is
+	// generated when the API is enabled without one.
+	Token string `json:"token"`
+}
+
+// Address returns the localhost address the API listens on
+func (c APIConfig) Address() string {
+	port := c.Port
+	if port <= 0 {
+		port = DefaultAPIPort
+	}
+	return fmt.Sprintf("127.0.0.1:%d", port)
+}
+
+// Database is a named database file the user can switch to
+type Database struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
 }
 
 // Site represents per-site configuration
@@ -21,6 +149,18 @@ type Site struct {
 	Name      string `json:"name"`
 	WatchPath string `json:"watch_path"`
 	Enabled   bool   `json:"enabled"`
+	// MaxDepth limits how many levels of subdirectories below WatchPath are
+	// watched. Zero means no limit.
+	MaxDepth int `json:"max_depth"`
+	// IgnorePatterns are filepath.Match patterns for file and directory
+	// names that should not be watched (e.g. "Backup*")
+	IgnorePatterns []string `json:"ignore_patterns"`
+	// WatchMode selects change detection: "auto" (default), "notify" for
+	// file system notifications or "poll" for periodic rescans, which also
+	// works on network shares
+	WatchMode string `json:"watch_mode"`
+	// PollInterval is the rescan interval in seconds when polling
+	PollInterval int `json:"poll_interval"`
 }
 
 // GetConfigDir returns the platform-specific configuration directory
@@ -114,6 +254,7 @@ func GetDefaultConfig() (*Config, error) {
 	}
 
 	defaultWatchPath := DetectPokerStarsPath()
+	defaultDatabasePath := filepath.Join(configDir, "poker.db")
 
 	return &Config{
 		HeroName: "",
@@ -122,10 +263,40 @@ func GetDefaultConfig() (*Config, error) {
 				Name:      "PokerStars",
 				WatchPath: defaultWatchPath,
 				Enabled:   true,
+				WatchMode: "auto",
 			},
 		},
-		DatabasePath: filepath.Join(configDir, "poker.db"),
-		Theme:        "dark",
+		DatabasePath: defaultDatabasePath,
+		Databases: []Database{
+			{Name: "Default", Path: defaultDatabasePath},
+		},
+		Theme: "dark",
+		Watcher: WatcherConfig{
+			Workers:    3,
+			QueueSize:  1000,
+			DebounceMs: DefaultDebounceMs,
+		},
+		Database: DatabaseConfig{
+			JournalMode:   "WAL",
+			Synchronous:   "NORMAL",
+			BusyTimeoutMs: 5000,
+			MaxReaders:    4,
+		},
+		Backup: BackupConfig{
+			Enabled:       true,
+			IntervalHours: 24,
+			Keep:          7,
+		},
+		Sessions: SessionConfig{
+			GapMinutes: DefaultSessionGapMinutes,
+		},
+		HUD: HUDConfig{
+			Enabled: true,
+			Port:    DefaultHUDPort,
+		},
+		API: APIConfig{
+			Port: DefaultAPIPort,
+		},
 	}, nil
 }
 
@@ -168,3 +339,83 @@ func GetDatabasePath() (string, error) {
 	}
 	return filepath.Join(configDir, "poker.db"), nil
 }
+
+// ResolveDatabasePath returns the configured database path, falling back to
+// the default location when none is set
+func (c *Config) ResolveDatabasePath() (string, error) {
+	if c.DatabasePath != "" {
+		return c.DatabasePath, nil
+	}
+	return GetDatabasePath()
+}
+
+// ResolveBackupDir returns the configured backup directory, falling back to
+// "backups" in the configuration directory
+func (c *Config) ResolveBackupDir() (string, error) {
+	if c.Backup.Directory != "" {
+		return c.Backup.Directory, nil
+	}
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "backups"), nil
+}
+
+// FindDatabase returns the registered database with the given path
+func (c *Config) FindDatabase(path string) (Database, bool) {
+	for _, db := range c.Databases {
+		if db.Path == path {
+			return db, true
+		}
+	}
+	return Database{}, false
+}
+
+// AddDatabase registers a database, replacing the name of an existing
+// entry with the same path
+func (c *Config) AddDatabase(name, path string) {
+	for i, db := range c.Databases {
+		if db.Path == path {
+			c.Databases[i].Name = name
+			return
+		}
+	}
+	c.Databases = append(c.Databases, Database{Name: name, Path: path})
+}
+
+// RemoveDatabase unregisters the database with the given path. The file
+// itself is left in place.
+func (c *Config) RemoveDatabase(path string) {
+	for i, db := range c.Databases {
+		if db.Path == path {
+			c.Databases = append(c.Databases[:i], c.Databases[i+1:]...)
+			return
+		}
+	}
+}
+
+// NewDatabasePath returns the path for a new database file named after name
+// in the configuration directory
+func NewDatabasePath(name string) (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	fileName := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			return r
+		case unicode.IsSpace(r):
+			return '_'
+		default:
+			return -1
+		}
+	}, strings.TrimSpace(name))
+	if fileName == "" {
+		return "", fmt.Errorf("invalid database name: %q", name)
+	}
+
+	return filepath.Join(configDir, fileName+".db"), nil
+}
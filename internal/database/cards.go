@@ -0,0 +1,55 @@
+package database
+
+import (
+	"aniki/internal/poker"
+)
+
+// SetCards fills in the normalized card rows, hand class and flop texture
+// of h from the hero's hole cards and the board. Cards that cannot be
+// parsed are ignored.
+func (h *Hand) SetCards(holeCards, board []string) {
+	h.Cards = h.Cards[:0]
+	h.HandClass = ""
+	h.FlopSuits, h.FlopPaired, h.FlopConnected, h.FlopHighRank = 0, false, false, 0
+
+	if hole, err := poker.ParseCards(holeCards); err == nil {
+		h.Cards = append(h.Cards, cardRows(CardHole, h.HeroName, hole)...)
+		h.HandClass = poker.HandClass(hole)
+	}
+
+	if cards, err := poker.ParseCards(board); err == nil {
+		h.Cards = append(h.Cards, cardRows(CardBoard, "", cards)...)
+		if texture, ok := poker.FlopTexture(cards); ok {
+			h.FlopSuits = texture.Suits
+			h.FlopPaired = texture.Paired
+			h.FlopConnected = texture.Connected
+			h.FlopHighRank = texture.HighRank + 2
+		}
+	}
+}
+
+// AddShownCards adds the hole cards a player showed to the card rows of h.
+// The hero's cards are already added by SetCards.
+func (h *Hand) AddShownCards(playerName string, cards []string) {
+	if playerName == h.HeroName {
+		return
+	}
+	if hole, err := poker.ParseCards(cards); err == nil {
+		h.Cards = append(h.Cards, cardRows(CardHole, playerName, hole)...)
+	}
+}
+
+// cardRows converts cards to HandCard rows in order
+func cardRows(kind, playerName string, cards []poker.Card) []HandCard {
+	rows := make([]HandCard, len(cards))
+	for i, c := range cards {
+		rows[i] = HandCard{
+			Kind:       kind,
+			PlayerName: playerName,
+			Slot:       i,
+			Rank:       c.Rank() + 2,
+			Suit:       string(poker.SuitChar(c.Suit())),
+		}
+	}
+	return rows
+}
@@ -1,23 +1,104 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
 // DB wraps the GORM database connection
 type DB struct {
 	*gorm.DB
+	resolver *dbresolver.DBResolver
 }
 
-// New creates a new database connection and runs auto-migrations
-func New(dbPath string) (*DB, error) {
-	gormDB, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
-		Logger:                                   logger.Default.LogMode(logger.Silent),
-		DisableForeignKeyConstraintWhenMigrating: true,
+// Options configures the SQLite connection. Zero values select the defaults.
+type Options struct {
+	// JournalMode is the SQLite journal mode (default "WAL"). Concurrent
+	// readers are only enabled in WAL mode.
+	JournalMode string
+	// Synchronous is the SQLite synchronous setting (default "NORMAL",
+	// which is durable across application crashes in WAL mode)
+	Synchronous string
+	// BusyTimeout is how long a connection waits for a lock before
+	// failing with "database is locked" (default 5s)
+	BusyTimeout time.Duration
+	// MaxReaders is the size of the read-only connection pool (default 4)
+	MaxReaders int
+	// DisablePrepareStmt turns off GORM's prepared statement cache
+	DisablePrepareStmt bool
+}
+
+var (
+	journalModes = map[string]bool{"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true}
+	syncModes    = map[string]bool{"OFF": true, "NORMAL": true, "FULL": true, "EXTRA": true}
+)
+
+// withDefaults fills in zero fields and validates the rest
+func (o Options) withDefaults() (Options, error) {
+	o.JournalMode = strings.ToUpper(o.JournalMode)
+	if o.JournalMode == "" {
+		o.JournalMode = "WAL"
+	}
+	if !journalModes[o.JournalMode] {
+		return o, fmt.Errorf("invalid journal mode %q", o.JournalMode)
+	}
+
+	o.Synchronous = strings.ToUpper(o.Synchronous)
+	if o.Synchronous == "" {
+		o.Synchronous = "NORMAL"
+	}
+	if !syncModes[o.Synchronous] {
+		return o, fmt.Errorf("invalid synchronous mode %q", o.Synchronous)
+	}
+
+	if o.BusyTimeout <= 0 {
+		o.BusyTimeout = 5 * time.Second
+	}
+	if o.MaxReaders <= 0 {
+		o.MaxReaders = 4
+	}
+	return o, nil
+}
+
+// dsn builds a go-sqlite3 connection string applying the pragmas to every
+// connection in the pool
+func (o Options) dsn(dbPath string, readOnly bool) string {
+	params := url.Values{}
+	params.Set("_journal_mode", o.JournalMode)
+	params.Set("_synchronous", o.Synchronous)
+	params.Set("_busy_timeout", fmt.Sprint(o.BusyTimeout.Milliseconds()))
+	params.Set("_foreign_keys", "1")
+	if readOnly {
+		params.Set("_query_only", "1")
+	} else {
+		// Take the write lock up front so a transaction never fails
+		// trying to upgrade from a read lock
+		params.Set("_txlock", "immediate")
+	}
+	return dbPath + "?" + params.Encode()
+}
+
+// New creates a new database connection and applies schema migrations. Writes go
+// through a single connection, since SQLite only allows one writer at a
+// time, while reads use a separate pool that in WAL mode runs concurrently
+// with the writer.
+func New(dbPath string, opts Options) (*DB, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	gormDB, err := gorm.Open(sqlite.Open(opts.dsn(dbPath, false)), &gorm.Config{
+		Logger:      logger.Default.LogMode(logger.Silent),
+		PrepareStmt: !opts.DisablePrepareStmt,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -26,44 +107,74 @@ func New(dbPath string) (*DB, error) {
 	// Get underlying sql.DB
 	sqlDB, err := gormDB.DB()
 	if err != nil {
+		if pool, ok := gormDB.ConnPool.(interface{ Close() error }); ok {
+			pool.Close()
+		}
 		return nil, fmt.Errorf("failed to get sql.DB: %w", err)
 	}
+	limitWriter(sqlDB)
 
 	db := &DB{DB: gormDB}
 
-	// Disable foreign keys temporarily for migration
+	// Disable foreign keys so migrations can rebuild tables
 	if _, err := sqlDB.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to disable foreign keys: %w", err)
 	}
 
-	// Run auto-migrations
-	if err := db.autoMigrate(); err != nil {
+	// Apply pending schema migrations
+	if err := db.migrate(dbPath); err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to run migrations: %w", err)
 	}
 
 	// Re-enable foreign keys
 	if _, err := sqlDB.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
+	// Outside WAL mode readers block the writer, so a reader pool only
+	// adds lock contention
+	if opts.JournalMode == "WAL" {
+		db.resolver = dbresolver.Register(dbresolver.Config{
+			Replicas: []gorm.Dialector{sqlite.Open(opts.dsn(dbPath, true))},
+		}).
+			SetMaxOpenConns(opts.MaxReaders).
+			SetMaxIdleConns(opts.MaxReaders)
+		if err := gormDB.Use(db.resolver); err != nil {
+			// Close the replicas the resolver managed to open as well
+			db.Close()
+			return nil, fmt.Errorf("failed to open read pool: %w", err)
+		}
+		// The resolver applies its pool limits to the writer as well
+		limitWriter(sqlDB)
+	}
+
 	return db, nil
 }
 
-// Close closes the database connection
+// limitWriter restricts the write pool to a single long-lived connection
+func limitWriter(sqlDB *sql.DB) {
+	sqlDB.SetMaxOpenConns(1)
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+}
+
+// Close closes the database connection and the read pool
 func (db *DB) Close() error {
+	if db.resolver != nil {
+		db.resolver.Call(func(connPool gorm.ConnPool) error {
+			if sqlDB, ok := connPool.(*sql.DB); ok {
+				sqlDB.Close()
+			}
+			return nil
+		})
+	}
+
 	sqlDB, err := db.DB.DB()
 	if err != nil {
 		return err
 	}
 	return sqlDB.Close()
 }
-
-// autoMigrate runs GORM auto-migrations for all models
-func (db *DB) autoMigrate() error {
-	return db.AutoMigrate(
-		&Site{},
-		&Hand{},
-		&Player{},
-		&Action{},
-	)
-}
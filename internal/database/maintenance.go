@@ -0,0 +1,139 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// IntegrityCheck runs SQLite's integrity check and returns the problems
+// found, which is empty for a healthy database
+func (db *DB) IntegrityCheck() ([]string, error) {
+	return integrityCheck(db.DB)
+}
+
+// Compact rebuilds the database file to reclaim space left by deleted rows,
+// truncates the write-ahead log and refreshes query planner statistics
+func (db *DB) Compact() error {
+	if err := db.Exec("VACUUM").Error; err != nil {
+		return fmt.Errorf("failed to vacuum: %w", err)
+	}
+	if err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
+		return fmt.Errorf("failed to checkpoint: %w", err)
+	}
+	if err := db.Exec("PRAGMA optimize").Error; err != nil {
+		return fmt.Errorf("failed to optimize: %w", err)
+	}
+	return nil
+}
+
+// ValidateFile checks that path is an intact Aniki database this build can
+// open, returning its schema version
+func ValidateFile(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	gormDB, err := gorm.Open(sqlite.Open(path+"?_query_only=1"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to open database: %w", err)
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+	defer sqlDB.Close()
+
+	problems, err := integrityCheck(gormDB)
+	if err != nil {
+		return 0, err
+	}
+	if len(problems) > 0 {
+		return 0, fmt.Errorf("integrity check failed: %s", problems[0])
+	}
+
+	if !gormDB.Migrator().HasTable("hands") {
+		return 0, fmt.Errorf("not an Aniki database: hands table missing")
+	}
+
+	// Databases from before versioned migrations have no schema_migrations
+	version := 0
+	if gormDB.Migrator().HasTable("schema_migrations") {
+		if err := gormDB.Raw("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version).Error; err != nil {
+			return 0, fmt.Errorf("failed to read schema version: %w", err)
+		}
+	}
+	if version > latestVersion() {
+		return 0, fmt.Errorf("database schema version %d is newer than the supported version %d", version, latestVersion())
+	}
+
+	return version, nil
+}
+
+// ReplaceFile overwrites the database file at dbPath with a copy of src.
+// The database must be closed. Stale write-ahead log files are removed so
+// they are not replayed onto the new file.
+func ReplaceFile(dbPath, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmpPath := dbPath + ".restore"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+
+	return os.Rename(tmpPath, dbPath)
+}
+
+// integrityCheck runs PRAGMA integrity_check on conn
+func integrityCheck(conn *gorm.DB) ([]string, error) {
+	rows, err := conn.Raw("PRAGMA integrity_check").Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line sql.NullString
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		if line.String != "ok" {
+			problems = append(problems, line.String)
+		}
+	}
+	return problems, rows.Err()
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migration is a single schema change. Migrations are applied in version
+// order, each in its own transaction, and are never edited once released:
+// schema changes always go into a new migration appended to the list.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+}
+
+// schemaMigration records an applied migration in the schema_migrations table
+type schemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// latestVersion returns the schema version this build migrates to
+func latestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies all pending migrations, taking a backup of an existing
+// database first
+func (db *DB) migrate(dbPath string) error {
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at datetime NOT NULL
+	)`).Error
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	latest := latestVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	hasData, err := db.hasUserTables()
+	if err != nil {
+		return err
+	}
+	if hasData && !isMemoryPath(dbPath) {
+		backupPath := fmt.Sprintf("%s.v%d-%s.bak", dbPath, current, time.Now().Format("20060102-150405"))
+		if err := db.BackupTo(backupPath); err != nil {
+			return fmt.Errorf("failed to back up database before migrating: %w", err)
+		}
+		log.Printf("Backed up database to %s before migrating", backupPath)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{
+				Version:   m.version,
+				Name:      m.name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+
+		log.Printf("Applied migration %d: %s", m.version, m.name)
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the version of the last applied migration, or 0 for
+// a database that has never been migrated
+func (db *DB) SchemaVersion() (int, error) {
+	var version int
+	err := db.Raw("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// hasUserTables reports whether the database holds any application tables,
+// i.e. whether it was created by an earlier version rather than just now
+func (db *DB) hasUserTables() (bool, error) {
+	var count int64
+	err := db.Raw(`SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')`).
+		Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	return count > 0, nil
+}
+
+// BackupTo writes a consistent copy of the database to path, which must not
+// exist yet. It is safe to call while the database is in use.
+func (db *DB) BackupTo(path string) error {
+	return db.Exec("VACUUM INTO ?", path).Error
+}
+
+// isMemoryPath reports whether dbPath refers to an in-memory database
+func isMemoryPath(dbPath string) bool {
+	return dbPath == ":memory:" || strings.Contains(dbPath, "mode=memory")
+}
+
+// execAll runs each statement in order, stopping at the first error
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
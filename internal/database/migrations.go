@@ -0,0 +1,389 @@
+package database
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"aniki/internal/poker"
+
+	"gorm.io/gorm"
+)
+
+// migrations lists every schema change in the order it is applied
+var migrations = []migration{
+	{1, "initial schema", migrateInitialSchema},
+	{2, "unique hand id per site", migrateSiteHandIndex},
+	{3, "big blind and all-in actions", migrateBigBlindAllIn},
+	{4, "structured cards", migrateStructuredCards},
+	{5, "showdown hands", migrateShowdownHands},
+	{6, "all-in equity", migrateAllInEquity},
+	{7, "opponents", migrateOpponents},
+	{8, "notes, tags and color labels", migrateNotes},
+	{9, "preflop player stats", migratePreflopStats},
+	{10, "table positions", migratePositions},
+}
+
+// migrateInitialSchema creates the tables previously managed by GORM
+// AutoMigrate. Databases created by AutoMigrate already have them, so every
+// statement is idempotent.
+func migrateInitialSchema(tx *gorm.DB) error {
+	return execAll(tx,
+		"CREATE TABLE IF NOT EXISTS `sites` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`watch_path` text,`enabled` numeric DEFAULT true,`created_at` datetime,CONSTRAINT `uni_sites_name` UNIQUE (`name`))",
+		"CREATE TABLE IF NOT EXISTS `hands` (`id` integer PRIMARY KEY AUTOINCREMENT,`site_id` integer NOT NULL,`hand_id` text NOT NULL,`game_type` text,`stakes` text,`table_name` text,`date_time` datetime,`hero_name` text,`position` text,`hole_cards` text,`board` text,`result` real DEFAULT 0,`rake` real DEFAULT 0,`total_pot` real DEFAULT 0,`parsed_data` text,`raw_text` text,`created_at` datetime)",
+		"CREATE INDEX IF NOT EXISTS `idx_hands_site_id` ON `hands`(`site_id`)",
+		"CREATE INDEX IF NOT EXISTS `idx_hands_game_type` ON `hands`(`game_type`)",
+		"CREATE INDEX IF NOT EXISTS `idx_hands_date_time` ON `hands`(`date_time`)",
+		"CREATE INDEX IF NOT EXISTS `idx_hands_hero_name` ON `hands`(`hero_name`)",
+		"CREATE TABLE IF NOT EXISTS `players` (`id` integer PRIMARY KEY AUTOINCREMENT,`hand_id` integer NOT NULL,`name` text NOT NULL,`seat` integer,`stack` real,`position` text,`created_at` datetime)",
+		"CREATE INDEX IF NOT EXISTS `idx_players_hand_id` ON `players`(`hand_id`)",
+		"CREATE TABLE IF NOT EXISTS `actions` (`id` integer PRIMARY KEY AUTOINCREMENT,`hand_id` integer NOT NULL,`player_name` text NOT NULL,`action` text NOT NULL,`amount` real DEFAULT 0,`street` text NOT NULL,`sequence` integer NOT NULL,`created_at` datetime)",
+		"CREATE INDEX IF NOT EXISTS `idx_actions_hand_id` ON `actions`(`hand_id`)",
+	)
+}
+
+// migrateSiteHandIndex (re)creates idx_site_hand on (site_id, hand_id).
+// Early databases had it on hand_id alone, which rejected hands from
+// different sites sharing an ID.
+func migrateSiteHandIndex(tx *gorm.DB) error {
+	return execAll(tx,
+		"DROP INDEX IF EXISTS `idx_site_hand`",
+		"CREATE UNIQUE INDEX `idx_site_hand` ON `hands`(`site_id`,`hand_id`)",
+	)
+}
+
+// migrateBigBlindAllIn adds hands.big_blind and actions.all_in, which
+// search needs for results in big blinds and all-in hands, and fills them
+// in for hands imported before they existed
+func migrateBigBlindAllIn(tx *gorm.DB) error {
+	err := execAll(tx,
+		"ALTER TABLE `hands` ADD COLUMN `big_blind` real DEFAULT 0",
+		"ALTER TABLE `actions` ADD COLUMN `all_in` numeric DEFAULT false",
+		"CREATE INDEX IF NOT EXISTS `idx_hands_position` ON `hands`(`position`)",
+		"UPDATE `hands` SET `big_blind` = COALESCE((SELECT MAX(`amount`) FROM `actions` WHERE `actions`.`hand_id` = `hands`.`id` AND `actions`.`action` = 'posts big blind'), 0)",
+	)
+	if err != nil {
+		return err
+	}
+
+	// Hands without a posted big blind fall back to their stakes, e.g. "$0.50/$1"
+	var stakes []struct {
+		ID     int64
+		Stakes string
+	}
+	if err := tx.Raw("SELECT `id`, `stakes` FROM `hands` WHERE `big_blind` = 0 AND `stakes` LIKE '%/%'").Scan(&stakes).Error; err != nil {
+		return err
+	}
+	for _, row := range stakes {
+		parts := strings.Split(row.Stakes, "/")
+		bb, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(parts[len(parts)-1]), "$"), 64)
+		if err != nil || bb <= 0 {
+			continue
+		}
+		if err := tx.Exec("UPDATE `hands` SET `big_blind` = ? WHERE `id` = ?", bb, row.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Recover all-in flags by replaying action lines from the raw text the
+	// same way the parser numbered them
+	actionLine := regexp.MustCompile(`^([^:]+):\s+(folds|checks|calls|bets|raises|posts small blind|posts big blind|posts the ante)`)
+	var raw []struct {
+		ID      int64
+		RawText string
+	}
+	if err := tx.Raw("SELECT `id`, `raw_text` FROM `hands` WHERE `raw_text` LIKE '%and is all-in%'").Scan(&raw).Error; err != nil {
+		return err
+	}
+	for _, row := range raw {
+		sequence := 0
+		for _, line := range strings.Split(row.RawText, "\n") {
+			if !actionLine.MatchString(line) {
+				continue
+			}
+			if strings.Contains(line, "and is all-in") {
+				err := tx.Exec("UPDATE `actions` SET `all_in` = true WHERE `hand_id` = ? AND `sequence` = ?", row.ID, sequence).Error
+				if err != nil {
+					return err
+				}
+			}
+			sequence++
+		}
+	}
+
+	return nil
+}
+
+// migrateStructuredCards adds the hand_cards table and the hand class and
+// flop texture columns, and derives them from the JSON hole cards and
+// board of existing hands
+func migrateStructuredCards(tx *gorm.DB) error {
+	err := execAll(tx,
+		"CREATE TABLE IF NOT EXISTS `hand_cards` (`id` integer PRIMARY KEY AUTOINCREMENT,`hand_id` integer NOT NULL,`kind` text NOT NULL,`player_name` text,`slot` integer,`rank` integer NOT NULL,`suit` text NOT NULL,CONSTRAINT `fk_hands_cards` FOREIGN KEY (`hand_id`) REFERENCES `hands`(`id`) ON DELETE CASCADE)",
+		"CREATE INDEX IF NOT EXISTS `idx_hand_cards_hand_id` ON `hand_cards`(`hand_id`)",
+		"CREATE INDEX IF NOT EXISTS `idx_hand_cards_card` ON `hand_cards`(`kind`,`rank`,`suit`)",
+		"ALTER TABLE `hands` ADD COLUMN `hand_class` text DEFAULT ''",
+		"ALTER TABLE `hands` ADD COLUMN `flop_suits` integer DEFAULT 0",
+		"ALTER TABLE `hands` ADD COLUMN `flop_paired` numeric DEFAULT false",
+		"ALTER TABLE `hands` ADD COLUMN `flop_connected` numeric DEFAULT false",
+		"ALTER TABLE `hands` ADD COLUMN `flop_high_rank` integer DEFAULT 0",
+		"CREATE INDEX IF NOT EXISTS `idx_hands_hand_class` ON `hands`(`hand_class`)",
+	)
+	if err != nil {
+		return err
+	}
+
+	const batchSize = 1000
+	var lastID int64
+	for {
+		var rows []Hand
+		err := tx.Select("id", "hero_name", "hole_cards", "board").
+			Where("id > ?", lastID).Order("id").Limit(batchSize).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		var cards []HandCard
+		for i := range rows {
+			hand := &rows[i]
+			lastID = hand.ID
+
+			var holeCards, board []string
+			json.Unmarshal([]byte(hand.HoleCards), &holeCards)
+			json.Unmarshal([]byte(hand.Board), &board)
+			hand.SetCards(holeCards, board)
+
+			err := tx.Model(&Hand{}).Where("id = ?", hand.ID).Updates(map[string]interface{}{
+				"hand_class":     hand.HandClass,
+				"flop_suits":     hand.FlopSuits,
+				"flop_paired":    hand.FlopPaired,
+				"flop_connected": hand.FlopConnected,
+				"flop_high_rank": hand.FlopHighRank,
+			}).Error
+			if err != nil {
+				return err
+			}
+
+			for _, card := range hand.Cards {
+				card.HandID = hand.ID
+				cards = append(cards, card)
+			}
+		}
+
+		if len(cards) > 0 {
+			if err := tx.CreateInBatches(cards, 500).Error; err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// migrateShowdownHands adds the cards players showed and their made hand
+// to players, recovering both for existing hands from the raw text
+func migrateShowdownHands(tx *gorm.DB) error {
+	err := execAll(tx,
+		"ALTER TABLE `players` ADD COLUMN `cards` text",
+		"ALTER TABLE `players` ADD COLUMN `made_hand` text",
+	)
+	if err != nil {
+		return err
+	}
+
+	shows := regexp.MustCompile(`^([^:]+): shows \[([^\]]+)\]`)
+	var rows []struct {
+		ID       int64
+		HeroName string
+		Board    string
+		RawText  string
+	}
+	err = tx.Raw("SELECT `id`, `hero_name`, `board`, `raw_text` FROM `hands` WHERE `raw_text` LIKE '%: shows [%'").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		var boardCards []string
+		json.Unmarshal([]byte(row.Board), &boardCards)
+		board, err := poker.ParseCards(boardCards)
+		if err != nil {
+			continue
+		}
+
+		lines := strings.Split(row.RawText, "\n")
+		gameType := lines[0] // the header line names the game
+
+		hand := Hand{ID: row.ID, HeroName: row.HeroName}
+		for _, line := range lines {
+			matches := shows.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+			name, shown := strings.TrimSpace(matches[1]), strings.Fields(matches[2])
+			hole, err := poker.ParseCards(shown)
+			if err != nil {
+				continue
+			}
+
+			cardsJSON, _ := json.Marshal(shown)
+			err = tx.Exec("UPDATE `players` SET `cards` = ?, `made_hand` = ? WHERE `hand_id` = ? AND `name` = ?",
+				string(cardsJSON), poker.MadeHand(gameType, hole, board), row.ID, name).Error
+			if err != nil {
+				return err
+			}
+			hand.AddShownCards(name, shown)
+		}
+
+		for i := range hand.Cards {
+			hand.Cards[i].HandID = row.ID
+		}
+		if len(hand.Cards) > 0 {
+			if err := tx.Create(&hand.Cards).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// migrateAllInEquity adds the hero's all-in equity and EV to hands. They
+// are calculated at import, so existing hands are left without them and
+// count at their actual result.
+func migrateAllInEquity(tx *gorm.DB) error {
+	return execAll(tx,
+		"ALTER TABLE `hands` ADD COLUMN `all_in_equity` real",
+		"ALTER TABLE `hands` ADD COLUMN `all_in_ev` real",
+	)
+}
+
+// migrateOpponents adds the opponents table, one row per screen name and
+// site, and links existing players to it
+func migrateOpponents(tx *gorm.DB) error {
+	return execAll(tx,
+		"CREATE TABLE IF NOT EXISTS `opponents` (`id` integer PRIMARY KEY AUTOINCREMENT,`site_id` integer NOT NULL,`name` text NOT NULL,`merged_into_id` integer,`first_seen` datetime,`last_seen` datetime,`created_at` datetime,CONSTRAINT `fk_opponents_site` FOREIGN KEY (`site_id`) REFERENCES `sites`(`id`))",
+		"CREATE UNIQUE INDEX IF NOT EXISTS `idx_opponent_site_name` ON `opponents`(`site_id`,`name`)",
+		"CREATE INDEX IF NOT EXISTS `idx_opponents_merged_into_id` ON `opponents`(`merged_into_id`)",
+		"CREATE INDEX IF NOT EXISTS `idx_opponents_last_seen` ON `opponents`(`last_seen`)",
+		"ALTER TABLE `players` ADD COLUMN `opponent_id` integer",
+		"CREATE INDEX IF NOT EXISTS `idx_players_opponent_id` ON `players`(`opponent_id`)",
+		"INSERT INTO `opponents` (`site_id`,`name`,`first_seen`,`last_seen`,`created_at`) SELECT `hands`.`site_id`, `players`.`name`, MIN(`hands`.`date_time`), MAX(`hands`.`date_time`), CURRENT_TIMESTAMP FROM `players` JOIN `hands` ON `hands`.`id` = `players`.`hand_id` GROUP BY `hands`.`site_id`, `players`.`name`",
+		"UPDATE `players` SET `opponent_id` = (SELECT `opponents`.`id` FROM `opponents` JOIN `hands` ON `hands`.`site_id` = `opponents`.`site_id` WHERE `hands`.`id` = `players`.`hand_id` AND `opponents`.`name` = `players`.`name`)",
+	)
+}
+
+// migrateNotes adds the notes, tags and color_labels tables
+func migrateNotes(tx *gorm.DB) error {
+	return execAll(tx,
+		"CREATE TABLE IF NOT EXISTS `notes` (`id` integer PRIMARY KEY AUTOINCREMENT,`opponent_id` integer,`hand_id` integer,`text` text NOT NULL,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_notes_opponent` FOREIGN KEY (`opponent_id`) REFERENCES `opponents`(`id`) ON DELETE CASCADE,CONSTRAINT `fk_notes_hand` FOREIGN KEY (`hand_id`) REFERENCES `hands`(`id`) ON DELETE CASCADE)",
+		"CREATE INDEX IF NOT EXISTS `idx_notes_opponent_id` ON `notes`(`opponent_id`)",
+		"CREATE INDEX IF NOT EXISTS `idx_notes_hand_id` ON `notes`(`hand_id`)",
+		"CREATE TABLE IF NOT EXISTS `tags` (`id` integer PRIMARY KEY AUTOINCREMENT,`opponent_id` integer,`hand_id` integer,`name` text NOT NULL,CONSTRAINT `fk_tags_opponent` FOREIGN KEY (`opponent_id`) REFERENCES `opponents`(`id`) ON DELETE CASCADE,CONSTRAINT `fk_tags_hand` FOREIGN KEY (`hand_id`) REFERENCES `hands`(`id`) ON DELETE CASCADE)",
+		"CREATE INDEX IF NOT EXISTS `idx_tags_opponent_id` ON `tags`(`opponent_id`)",
+		"CREATE INDEX IF NOT EXISTS `idx_tags_hand_id` ON `tags`(`hand_id`)",
+		"CREATE INDEX IF NOT EXISTS `idx_tags_name` ON `tags`(`name`)",
+		"CREATE TABLE IF NOT EXISTS `color_labels` (`id` integer PRIMARY KEY AUTOINCREMENT,`opponent_id` integer,`hand_id` integer,`color` text NOT NULL,CONSTRAINT `fk_color_labels_opponent` FOREIGN KEY (`opponent_id`) REFERENCES `opponents`(`id`) ON DELETE CASCADE,CONSTRAINT `fk_color_labels_hand` FOREIGN KEY (`hand_id`) REFERENCES `hands`(`id`) ON DELETE CASCADE)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS `idx_color_labels_opponent_id` ON `color_labels`(`opponent_id`)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS `idx_color_labels_hand_id` ON `color_labels`(`hand_id`)",
+	)
+}
+
+// migratePreflopStats adds the preflop flags of players used by the HUD
+// and derives them from the actions of existing hands
+func migratePreflopStats(tx *gorm.DB) error {
+	err := execAll(tx,
+		"ALTER TABLE `players` ADD COLUMN `vpip` numeric DEFAULT false",
+		"ALTER TABLE `players` ADD COLUMN `pfr` numeric DEFAULT false",
+		"ALTER TABLE `players` ADD COLUMN `three_bet_chance` numeric DEFAULT false",
+		"ALTER TABLE `players` ADD COLUMN `three_bet` numeric DEFAULT false",
+	)
+	if err != nil {
+		return err
+	}
+
+	const batchSize = 1000
+	var lastID int64
+	for {
+		var hands []Hand
+		err := tx.Select("id").Where("id > ?", lastID).Order("id").Limit(batchSize).
+			Preload("Actions", "street = ?", "preflop", func(db *gorm.DB) *gorm.DB { return db.Order("sequence") }).
+			Find(&hands).Error
+		if err != nil {
+			return err
+		}
+		if len(hands) == 0 {
+			return nil
+		}
+
+		for _, hand := range hands {
+			lastID = hand.ID
+			for name, f := range preflopFlags(hand.Actions) {
+				if !f.vpip && !f.threeBetChance {
+					continue
+				}
+				err := tx.Model(&Player{}).Where("hand_id = ? AND name = ?", hand.ID, name).Updates(map[string]interface{}{
+					"vpip":             f.vpip,
+					"pfr":              f.pfr,
+					"three_bet_chance": f.threeBetChance,
+					"three_bet":        f.threeBet,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// migratePositions fills in the table positions of players and the hero
+// for hands imported before they were recorded, finding the button in the
+// raw text the same way the parser does
+func migratePositions(tx *gorm.DB) error {
+	button := regexp.MustCompile(`Seat #(\d+) is the button`)
+
+	const batchSize = 1000
+	var lastID int64
+	for {
+		var hands []Hand
+		err := tx.Select("id", "hero_name", "raw_text").
+			Where("id > ? AND (position IS NULL OR position = '') AND raw_text LIKE ?", lastID, "%is the button%").
+			Order("id").Limit(batchSize).
+			Preload("Players", func(db *gorm.DB) *gorm.DB { return db.Select("id", "hand_id", "name", "seat") }).
+			Find(&hands).Error
+		if err != nil {
+			return err
+		}
+		if len(hands) == 0 {
+			return nil
+		}
+
+		for _, hand := range hands {
+			lastID = hand.ID
+			matches := button.FindStringSubmatch(hand.RawText)
+			if matches == nil {
+				continue
+			}
+			buttonSeat, _ := strconv.Atoi(matches[1])
+
+			seats := make([]int, len(hand.Players))
+			for i, player := range hand.Players {
+				seats[i] = player.Seat
+			}
+			for i, position := range poker.Positions(seats, buttonSeat) {
+				player := hand.Players[i]
+				if err := tx.Exec("UPDATE `players` SET `position` = ? WHERE `id` = ?", position, player.ID).Error; err != nil {
+					return err
+				}
+				if player.Name == hand.HeroName {
+					if err := tx.Exec("UPDATE `hands` SET `position` = ? WHERE `id` = ?", position, hand.ID).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+}
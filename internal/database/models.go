@@ -16,38 +16,181 @@ type Site struct {
 
 // Hand represents a parsed poker hand
 type Hand struct {
-	ID         int64     `json:"id" gorm:"primaryKey;autoIncrement"`
-	SiteID     int       `json:"site_id" gorm:"not null;index"`
-	Site       *Site     `json:"site,omitempty" gorm:"foreignKey:SiteID"`
-	HandID     string    `json:"hand_id" gorm:"not null;uniqueIndex:idx_site_hand"`
-	GameType   string    `json:"game_type" gorm:"index"`
-	Stakes     string    `json:"stakes"`
-	TableName  string    `json:"table_name"`
-	DateTime   time.Time `json:"date_time" gorm:"index"`
-	HeroName   string    `json:"hero_name" gorm:"index"`
-	Position   string    `json:"position"`
-	HoleCards  string    `json:"hole_cards"` // JSON array of cards
-	Board      string    `json:"board"`      // JSON array of board cards
-	Result     float64   `json:"result" gorm:"default:0"`
-	Rake       float64   `json:"rake" gorm:"default:0"`
-	TotalPot   float64   `json:"total_pot" gorm:"default:0"`
-	ParsedData string    `json:"parsed_data" gorm:"type:text"`
-	RawText    string    `json:"raw_text" gorm:"type:text"`
-	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
-	Players    []Player  `json:"players,omitempty" gorm:"foreignKey:HandID;constraint:OnDelete:CASCADE"`
-	Actions    []Action  `json:"actions,omitempty" gorm:"foreignKey:HandID;constraint:OnDelete:CASCADE"`
+	ID            int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	SiteID        int        `json:"site_id" gorm:"not null;index;uniqueIndex:idx_site_hand"`
+	Site          *Site      `json:"site,omitempty" gorm:"foreignKey:SiteID"`
+	HandID        string     `json:"hand_id" gorm:"not null;uniqueIndex:idx_site_hand"`
+	GameType      string     `json:"game_type" gorm:"index"`
+	Stakes        string     `json:"stakes"`
+	TableName     string     `json:"table_name"`
+	DateTime      time.Time  `json:"date_time" gorm:"index"`
+	HeroName      string     `json:"hero_name" gorm:"index"`
+	Position      string     `json:"position"`
+	HoleCards     string     `json:"hole_cards"`              // JSON array of cards
+	Board         string     `json:"board"`                   // JSON array of board cards
+	HandClass     string     `json:"hand_class" gorm:"index"` // hero's starting hand, e.g. "AKs", "72o", "QQ"
+	FlopSuits     int        `json:"flop_suits"`              // distinct flop suits: 1 monotone, 2 two-tone, 3 rainbow, 0 no flop
+	FlopPaired    bool       `json:"flop_paired"`
+	FlopConnected bool       `json:"flop_connected"` // a straight is possible on the flop
+	FlopHighRank  int        `json:"flop_high_rank"` // 2-14
+	BigBlind      float64    `json:"big_blind" gorm:"default:0"`
+	Result        float64    `json:"result" gorm:"default:0"`
+	AllInEquity   *float64   `json:"all_in_equity"` // hero's equity when all-in before the river against known cards
+	AllInEV       *float64   `json:"all_in_ev"`     // result had the pot been split by equity
+	Rake          float64    `json:"rake" gorm:"default:0"`
+	TotalPot      float64    `json:"total_pot" gorm:"default:0"`
+	ParsedData    string     `json:"parsed_data" gorm:"type:text"`
+	RawText       string     `json:"raw_text" gorm:"type:text"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	Players       []Player   `json:"players,omitempty" gorm:"foreignKey:HandID;constraint:OnDelete:CASCADE"`
+	Actions       []Action   `json:"actions,omitempty" gorm:"foreignKey:HandID;constraint:OnDelete:CASCADE"`
+	Cards         []HandCard `json:"cards,omitempty" gorm:"foreignKey:HandID;constraint:OnDelete:CASCADE"`
 }
 
 // Player represents a player in a hand
 type Player struct {
-	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
-	HandID    int64     `json:"hand_id" gorm:"not null;index"`
-	Hand      *Hand     `json:"-" gorm:"foreignKey:HandID"`
-	Name      string    `json:"name" gorm:"not null"`
-	Seat      int       `json:"seat"`
-	Stack     float64   `json:"stack"`
-	Position  string    `json:"position"`
-	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	ID         int64   `json:"id" gorm:"primaryKey;autoIncrement"`
+	HandID     int64   `json:"hand_id" gorm:"not null;index"`
+	Hand       *Hand   `json:"-" gorm:"foreignKey:HandID"`
+	Name       string  `json:"name" gorm:"not null"`
+	Seat       int     `json:"seat"`
+	Stack      float64 `json:"stack"`
+	Position   string  `json:"position"`
+	Cards      string  `json:"cards"`     // JSON array of cards shown at showdown
+	MadeHand   string  `json:"made_hand"` // made-hand category of shown cards, e.g. "Flush"
+	OpponentID *int64  `json:"opponent_id" gorm:"index"`
+	// Preflop flags, set by Hand.SetPreflopStats
+	VPIP           bool      `json:"vpip" gorm:"column:vpip;default:false"`
+	PFR            bool      `json:"pfr" gorm:"column:pfr;default:false"`
+	ThreeBetChance bool      `json:"three_bet_chance" gorm:"default:false"`
+	ThreeBet       bool      `json:"three_bet" gorm:"default:false"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// PlayerStats are the preflop statistics of a player over all their hands
+type PlayerStats struct {
+	Hands    int     `json:"hands"`
+	VPIP     float64 `json:"vpip"`      // % of hands voluntarily put money in preflop
+	PFR      float64 `json:"pfr"`       // % of hands raised preflop
+	ThreeBet float64 `json:"three_bet"` // % of 3-bet chances taken
+}
+
+// Opponent is a screen name on a site, shared by every hand it played.
+// Aliases merged into another opponent keep their row with MergedIntoID
+// set, and their players point at the opponent they were merged into.
+type Opponent struct {
+	ID           int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	SiteID       int       `json:"site_id" gorm:"not null;uniqueIndex:idx_opponent_site_name"`
+	Site         *Site     `json:"site,omitempty" gorm:"foreignKey:SiteID"`
+	Name         string    `json:"name" gorm:"not null;uniqueIndex:idx_opponent_site_name"`
+	MergedIntoID *int64    `json:"merged_into_id,omitempty" gorm:"index"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen" gorm:"index"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Note is free text attached to an opponent or a hand. Exactly one of
+// OpponentID and HandID is set, as for Tag and ColorLabel.
+type Note struct {
+	ID         int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	OpponentID *int64    `json:"opponent_id,omitempty" gorm:"index"`
+	Opponent   *Opponent `json:"opponent,omitempty" gorm:"foreignKey:OpponentID"`
+	HandID     *int64    `json:"hand_id,omitempty" gorm:"index"`
+	Hand       *Hand     `json:"hand,omitempty" gorm:"foreignKey:HandID"`
+	Text       string    `json:"text" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Tag is a short label such as "fish", "reg" or "nit" on an opponent or a
+// hand
+type Tag struct {
+	ID         int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	OpponentID *int64 `json:"opponent_id,omitempty" gorm:"index"`
+	HandID     *int64 `json:"hand_id,omitempty" gorm:"index"`
+	Name       string `json:"name" gorm:"not null;index"`
+}
+
+// ColorLabel is the color an opponent or a hand is marked with
+type ColorLabel struct {
+	ID         int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	OpponentID *int64 `json:"opponent_id,omitempty" gorm:"uniqueIndex"`
+	HandID     *int64 `json:"hand_id,omitempty" gorm:"uniqueIndex"`
+	Color      string `json:"color" gorm:"not null"` // CSS color, e.g. "#ef4444"
+}
+
+// Note, tag and color label targets
+const (
+	TargetOpponent = "opponent"
+	TargetHand     = "hand"
+)
+
+// Target names the opponent or hand a note, tag or color label belongs to
+type Target struct {
+	Kind string `json:"kind"` // TargetOpponent or TargetHand
+	ID   int64  `json:"id"`
+}
+
+// Annotations are the notes, tags and color label of one target
+type Annotations struct {
+	Target Target   `json:"target"`
+	Notes  []Note   `json:"notes"`
+	Tags   []string `json:"tags"`
+	Color  string   `json:"color"`
+}
+
+// NotesExport is the portable form of every note, tag and color label.
+// Targets are identified by site and screen name or hand number rather
+// than database IDs so the export can be imported into another database.
+type NotesExport struct {
+	Version   int                `json:"version"`
+	Exported  time.Time          `json:"exported"`
+	Opponents []ExportedOpponent `json:"opponents"`
+	Hands     []ExportedHand     `json:"hands"`
+}
+
+// ExportedOpponent holds the annotations of an opponent in a NotesExport
+type ExportedOpponent struct {
+	Site  string         `json:"site"`
+	Name  string         `json:"name"`
+	Color string         `json:"color,omitempty"`
+	Tags  []string       `json:"tags,omitempty"`
+	Notes []ExportedNote `json:"notes,omitempty"`
+}
+
+// ExportedHand holds the annotations of a hand in a NotesExport
+type ExportedHand struct {
+	Site   string         `json:"site"`
+	HandID string         `json:"hand_id"`
+	Color  string         `json:"color,omitempty"`
+	Tags   []string       `json:"tags,omitempty"`
+	Notes  []ExportedNote `json:"notes,omitempty"`
+}
+
+// ExportedNote is a note in a NotesExport
+type ExportedNote struct {
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NotesImport reports the outcome of importing a NotesExport
+type NotesImport struct {
+	Notes   int `json:"notes"`   // notes added; notes with identical text are skipped
+	Tags    int `json:"tags"`    // tags added
+	Colors  int `json:"colors"`  // color labels set
+	Skipped int `json:"skipped"` // hands not found in this database
+}
+
+// OpponentStats summarizes the hands an opponent played with the hero
+type OpponentStats struct {
+	Opponent  Opponent  `json:"opponent"`
+	Aliases   []string  `json:"aliases"` // names merged into the opponent
+	Hands     int       `json:"hands"`
+	VPIP      float64   `json:"vpip"` // % of hands voluntarily put money in preflop
+	PFR       float64   `json:"pfr"`  // % of hands raised preflop
+	Showdowns int       `json:"showdowns"`
+	HeroWon   float64   `json:"hero_won"` // hero's net result in these hands
+	LastSeen  time.Time `json:"last_seen"`
 }
 
 // Action represents an action in a hand
@@ -60,9 +203,27 @@ type Action struct {
 	Amount     float64   `json:"amount" gorm:"default:0"`
 	Street     string    `json:"street" gorm:"not null"` // preflop, flop, turn, river
 	Sequence   int       `json:"sequence" gorm:"not null"`
+	AllIn      bool      `json:"all_in" gorm:"default:false"`
 	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime"`
 }
 
+// HandCard is a known card of a hand: a player's hole card or a board card
+type HandCard struct {
+	ID         int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	HandID     int64  `json:"hand_id" gorm:"not null;index"`
+	Kind       string `json:"kind" gorm:"not null"` // hole or board
+	PlayerName string `json:"player_name"`          // owner of a hole card
+	Slot       int    `json:"slot"`                 // order within the player's hand or the board
+	Rank       int    `json:"rank" gorm:"not null"` // 2-14
+	Suit       string `json:"suit" gorm:"not null"` // c, d, h or s
+}
+
+// Card kinds
+const (
+	CardHole  = "hole"
+	CardBoard = "board"
+)
+
 // HandFilter is used for querying hands
 type HandFilter struct {
 	SiteID   *int       `json:"site_id,omitempty"`
@@ -70,18 +231,127 @@ type HandFilter struct {
 	GameType string     `json:"game_type,omitempty"`
 	DateFrom *time.Time `json:"date_from,omitempty"`
 	DateTo   *time.Time `json:"date_to,omitempty"`
+	Tags     []string   `json:"tags,omitempty"` // hands tagged with any of these
 	Limit    int        `json:"limit,omitempty"`
 	Offset   int        `json:"offset,omitempty"`
 }
 
+// HandSearch is a structured hand query, usually compiled from the search
+// language in package search. Conditions are combined with AND; values
+// within a list are alternatives.
+type HandSearch struct {
+	Site      string            `json:"site,omitempty"`
+	HeroName  string            `json:"hero_name,omitempty"`
+	GameType  string            `json:"game_type,omitempty"`
+	Stakes    []string          `json:"stakes,omitempty"`
+	Tables    []string          `json:"tables,omitempty"`
+	Positions []string          `json:"positions,omitempty"` // hero position
+	Cards     []string          `json:"cards,omitempty"`     // hand classes ("AKs", "QQ", "AK"), exact cards ("AhKh") or "pair", "suited", "offsuit"
+	Flop      []string          `json:"flop,omitempty"`      // textures that must all hold: monotone, twotone, rainbow, paired, unpaired, connected
+	Board     []string          `json:"board,omitempty"`     // cards that must all be on the board
+	Streets   []string          `json:"streets,omitempty"`   // street the hand reached
+	Villains  []string          `json:"villains,omitempty"`  // opponent names, "*" as wildcard
+	AllIn     *bool             `json:"all_in,omitempty"`
+	Tags      []string          `json:"tags,omitempty"` // hand tags, any of which must be present
+	Result    []NumberCondition `json:"result,omitempty"`
+	Pot       []NumberCondition `json:"pot,omitempty"`
+	Text      []string          `json:"text,omitempty"` // hand ID or table name
+	DateFrom  *time.Time        `json:"date_from,omitempty"`
+	DateTo    *time.Time        `json:"date_to,omitempty"`
+	Limit     int               `json:"limit,omitempty"`
+	Offset    int               `json:"offset,omitempty"`
+}
+
+// NumberCondition compares a numeric hand column with a value
+type NumberCondition struct {
+	Op        string  `json:"op"` // <, <=, >, >=, =
+	Value     float64 `json:"value"`
+	BigBlinds bool    `json:"big_blinds"` // value is in big blinds rather than chips
+}
+
+// HandPage is one page of search results
+type HandPage struct {
+	Hands  []Hand `json:"hands"`
+	Total  int64  `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
 // Stats represents aggregated statistics
 type Stats struct {
 	TotalHands  int     `json:"total_hands"`
 	TotalWon    float64 `json:"total_won"`
+	EVWon       float64 `json:"ev_won"` // total won with all-in hands counted at their EV
+	AllInHands  int     `json:"all_in_hands"`
 	TotalRake   float64 `json:"total_rake"`
 	BiggestWin  float64 `json:"biggest_win"`
 	BiggestLoss float64 `json:"biggest_loss"`
-	WinRate     float64 `json:"win_rate"` // BB/100
+	WinRate     float64 `json:"win_rate"`    // BB/100
+	EVWinRate   float64 `json:"ev_win_rate"` // BB/100 with all-in hands counted at their EV
 	HandsWon    int     `json:"hands_won"`
 	HandsLost   int     `json:"hands_lost"`
 }
+
+// Graph intervals accepted by HandRepository.GetProfitGraph
+const (
+	GraphPerHand = "hand"
+	GraphDay     = "day"
+	GraphWeek    = "week"
+	GraphMonth   = "month"
+)
+
+// GraphPoint is one point of the winnings graph. Amounts are cumulative
+// from the first hand of the graph up to and including this point.
+type GraphPoint struct {
+	Time        time.Time `json:"time"`  // hand time, or the start of the bucket
+	Hands       int       `json:"hands"` // cumulative hand count
+	NetWon      float64   `json:"net_won"`
+	EVWon       float64   `json:"ev_won"`       // all-in hands counted at their EV
+	Showdown    float64   `json:"showdown"`     // won in hands where the hero reached showdown
+	NonShowdown float64   `json:"non_showdown"` // won in all other hands
+}
+
+// ProfitGraph is the cumulative winnings of a set of hands over time
+type ProfitGraph struct {
+	Interval string       `json:"interval"`
+	Points   []GraphPoint `json:"points"`
+}
+
+// Groupings accepted by HandRepository.GetGroupStats
+const (
+	GroupPosition = "position"
+	GroupStakes   = "stakes"
+	GroupOpponent = "opponent"
+	GroupTotal    = "total" // all matching hands in a single group
+)
+
+// GroupStats aggregates the hero's hands sharing a position, stakes or
+// opponent. For opponents, amounts are the hero's results in hands against
+// them and VPIP/PFR are the opponent's.
+type GroupStats struct {
+	Group    string  `json:"group"`
+	Hands    int     `json:"hands"`
+	NetWon   float64 `json:"net_won"`
+	EVWon    float64 `json:"ev_won"`
+	Rake     float64 `json:"rake"`
+	BBPer100 float64 `json:"bb_per_100"`
+	VPIP     float64 `json:"vpip"`
+	PFR      float64 `json:"pfr"`
+}
+
+// Session is a stretch of play without a break longer than the configured
+// session gap, possibly across several tables
+type Session struct {
+	HeroName        string    `json:"hero_name"`
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationMinutes float64   `json:"duration_minutes"`
+	Tables          []string  `json:"tables"`
+	Stakes          []string  `json:"stakes"`
+	Hands           int       `json:"hands"`
+	HandsPerHour    float64   `json:"hands_per_hour"`
+	NetWon          float64   `json:"net_won"`
+	EVWon           float64   `json:"ev_won"`
+	Rake            float64   `json:"rake"`
+	BBPer100        float64   `json:"bb_per_100"`
+}
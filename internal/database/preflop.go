@@ -0,0 +1,53 @@
+package database
+
+import "strings"
+
+// SetPreflopStats sets the preflop flags of every player of h from its
+// actions: whether they put money in voluntarily, raised, faced a single
+// raise they could 3-bet and 3-bet
+func (h *Hand) SetPreflopStats() {
+	flags := preflopFlags(h.Actions)
+	for i := range h.Players {
+		f := flags[h.Players[i].Name]
+		h.Players[i].VPIP = f.vpip
+		h.Players[i].PFR = f.pfr
+		h.Players[i].ThreeBetChance = f.threeBetChance
+		h.Players[i].ThreeBet = f.threeBet
+	}
+}
+
+// preflop holds the preflop flags of one player
+type preflop struct {
+	vpip, pfr, threeBetChance, threeBet bool
+}
+
+// preflopFlags replays the preflop actions in order. A player has a 3-bet
+// chance when they act facing exactly one raise, and 3-bets when they
+// raise it.
+func preflopFlags(actions []Action) map[string]preflop {
+	flags := map[string]preflop{}
+	raises := 0
+	for _, a := range actions {
+		if a.Street != "preflop" || strings.HasPrefix(a.Action, "posts") {
+			continue
+		}
+
+		f := flags[a.PlayerName]
+		if raises == 1 {
+			f.threeBetChance = true
+		}
+		switch a.Action {
+		case "calls":
+			f.vpip = true
+		case "bets", "raises":
+			f.vpip = true
+			f.pfr = true
+			if raises == 1 {
+				f.threeBet = true
+			}
+			raises++
+		}
+		flags[a.PlayerName] = f
+	}
+	return flags
+}
@@ -0,0 +1,174 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"aniki/internal/database"
+)
+
+// csvTime is the time layout spreadsheets recognise
+const csvTime = "2006-01-02 15:04:05"
+
+// utf8BOM makes Excel read the file as UTF-8, keeping screen names intact
+const utf8BOM = "\ufeff"
+
+var handColumns = []string{
+	"date_time", "site", "hand_id", "table", "game_type", "stakes", "hero", "position",
+	"hole_cards", "board", "big_blind", "result", "result_bb", "all_in_ev", "rake", "total_pot",
+}
+
+// csvWriter writes hands as CSV rows, starting with a header
+type csvWriter struct {
+	w      io.Writer
+	csv    *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) Writer {
+	return &csvWriter{w: w, csv: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) WriteHand(hand *database.Hand) error {
+	if !c.header {
+		if err := writeHeader(c.w, c.csv, handColumns); err != nil {
+			return err
+		}
+		c.header = true
+	}
+
+	site := ""
+	if hand.Site != nil {
+		site = hand.Site.Name
+	}
+	resultBB, allInEV := "", ""
+	if hand.BigBlind > 0 {
+		resultBB = formatFloat(hand.Result / hand.BigBlind)
+	}
+	if hand.AllInEV != nil {
+		allInEV = formatFloat(*hand.AllInEV)
+	}
+
+	return c.csv.Write([]string{
+		hand.DateTime.Format(csvTime),
+		csvText(site),
+		csvText(hand.HandID),
+		csvText(hand.TableName),
+		csvText(hand.GameType),
+		csvText(hand.Stakes),
+		csvText(hand.HeroName),
+		hand.Position,
+		joinCards(hand.HoleCards),
+		joinCards(hand.Board),
+		formatFloat(hand.BigBlind),
+		formatFloat(hand.Result),
+		resultBB,
+		allInEV,
+		formatFloat(hand.Rake),
+		formatFloat(hand.TotalPot),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	if !c.header {
+		// An empty export still gets its header
+		if err := writeHeader(c.w, c.csv, handColumns); err != nil {
+			return err
+		}
+		c.header = true
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+// WriteGroupStatsCSV writes grouped statistics as CSV, the first column
+// named after the grouping
+func WriteGroupStatsCSV(w io.Writer, group string, stats []database.GroupStats) error {
+	out := csv.NewWriter(w)
+	header := []string{group, "hands", "net_won", "ev_won", "rake", "bb_per_100", "vpip", "pfr"}
+	if err := writeHeader(w, out, header); err != nil {
+		return err
+	}
+	for _, s := range stats {
+		err := out.Write([]string{
+			csvText(s.Group),
+			strconv.Itoa(s.Hands),
+			formatFloat(s.NetWon),
+			formatFloat(s.EVWon),
+			formatFloat(s.Rake),
+			formatFloat(s.BBPer100),
+			formatFloat(s.VPIP),
+			formatFloat(s.PFR),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteSessionsCSV writes sessions as CSV, one row per session
+func WriteSessionsCSV(w io.Writer, sessions []database.Session) error {
+	out := csv.NewWriter(w)
+	header := []string{"start", "end", "duration_minutes", "tables", "stakes", "hands", "hands_per_hour", "net_won", "ev_won", "rake", "bb_per_100"}
+	if err := writeHeader(w, out, header); err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		err := out.Write([]string{
+			s.Start.Format(csvTime),
+			s.End.Format(csvTime),
+			formatFloat(s.DurationMinutes),
+			csvText(strings.Join(s.Tables, "; ")),
+			csvText(strings.Join(s.Stakes, "; ")),
+			strconv.Itoa(s.Hands),
+			formatFloat(s.HandsPerHour),
+			formatFloat(s.NetWon),
+			formatFloat(s.EVWon),
+			formatFloat(s.Rake),
+			formatFloat(s.BBPer100),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// writeHeader writes the byte order mark and the column names
+func writeHeader(w io.Writer, out *csv.Writer, columns []string) error {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	return out.Write(columns)
+}
+
+// csvText keeps a spreadsheet from running text such as a screen name or
+// table name as a formula, by prefixing it with a quote when it starts
+// like one
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// joinCards turns a JSON array of cards into "Ah Kd"
+func joinCards(data string) string {
+	var cards []string
+	if err := decodeCards(data, &cards); err != nil {
+		return ""
+	}
+	return strings.Join(cards, " ")
+}
+
+// formatFloat formats an amount rounded to hundredths, without trailing
+// zeros
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,92 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"aniki/internal/database"
+)
+
+func TestCSVText(t *testing.T) {
+	tests := []struct{ value, want string }{
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+1", "'+1"},
+		{"-cmd", "'-cmd"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tname", "'\tname"},
+		{"Hero", "Hero"},
+		{"a=b", "a=b"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := csvText(tt.value); got != tt.want {
+			t.Errorf("csvText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// readCSV parses CSV written with a byte order mark
+func readCSV(t *testing.T, data string) [][]string {
+	t.Helper()
+	rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(data, utf8BOM))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w := newCSVWriter(&buf)
+	hand := &database.Hand{
+		Site:      &database.Site{Name: "PokerStars"},
+		HandID:    "1",
+		TableName: "=cmd|' /C calc'!A0",
+		GameType:  "Hold'em No Limit",
+		Stakes:    "$0.50/$1",
+		HeroName:  "@Hero",
+		DateTime:  time.Date(2024, 3, 1, 20, 15, 0, 0, time.UTC),
+		BigBlind:  1,
+		Result:    -17,
+	}
+	if err := w.WriteHand(hand); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	row := readCSV(t, buf.String())[1]
+	column := func(name string) string {
+		for i, c := range handColumns {
+			if c == name {
+				return row[i]
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return ""
+	}
+	if got := column("table"); got != "'=cmd|' /C calc'!A0" {
+		t.Errorf("table %q", got)
+	}
+	if got := column("hero"); got != "'@Hero" {
+		t.Errorf("hero %q", got)
+	}
+	// Amounts stay numbers
+	if got := column("result"); got != "-17" {
+		t.Errorf("result %q", got)
+	}
+
+	buf.Reset()
+	stats := []database.GroupStats{{Group: "-Villain", Hands: 3, NetWon: -2}}
+	if err := WriteGroupStatsCSV(&buf, database.GroupOpponent, stats); err != nil {
+		t.Fatal(err)
+	}
+	row = readCSV(t, buf.String())[1]
+	if row[0] != "'-Villain" || row[2] != "-2" {
+		t.Errorf("group stats row %q", row)
+	}
+}
@@ -0,0 +1,93 @@
+// Package export writes stored hands to files in formats other tools can
+// read.
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"aniki/internal/database"
+)
+
+// Export formats
+const (
+	FormatOHH = "ohh" // Open Hand History JSON standard
+	FormatCSV = "csv" // one row per hand, for spreadsheets
+	// PokerStars hand histories regenerated from the stored actions
+	FormatPokerStars           = "pokerstars"
+	FormatPokerStarsAnonymized = "pokerstars-anonymized" // hero renamed "Hero"
+)
+
+// Writer writes hands one at a time in a format
+type Writer interface {
+	// WriteHand writes a hand loaded with its site, players and actions
+	WriteHand(hand *database.Hand) error
+	// Flush writes anything still buffered
+	Flush() error
+}
+
+// Format describes an export format
+type Format struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Extension   string `json:"extension"` // file extension including the dot
+	newWriter   func(w io.Writer) Writer
+}
+
+var formats = map[string]Format{
+	FormatOHH: {
+		Name:        FormatOHH,
+		Description: "Open Hand History (*.ohh)",
+		Extension:   ".ohh",
+		newWriter:   newOHHWriter,
+	},
+	FormatCSV: {
+		Name:        FormatCSV,
+		Description: "CSV, one row per hand (*.csv)",
+		Extension:   ".csv",
+		newWriter:   newCSVWriter,
+	},
+	FormatPokerStars: {
+		Name:        FormatPokerStars,
+		Description: "PokerStars hand history (*.txt)",
+		Extension:   ".txt",
+		newWriter:   newTextWriter,
+	},
+	FormatPokerStarsAnonymized: {
+		Name:        FormatPokerStarsAnonymized,
+		Description: "PokerStars hand history, hero anonymized (*.txt)",
+		Extension:   ".txt",
+		newWriter:   newAnonymizedTextWriter,
+	},
+}
+
+// Formats returns the supported formats ordered by name
+func Formats() []Format {
+	list := make([]Format, 0, len(formats))
+	for _, format := range formats {
+		list = append(list, format)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Lookup returns the named format
+func Lookup(name string) (Format, error) {
+	format, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unknown export format %q", name)
+	}
+	return format, nil
+}
+
+// NewWriter creates a writer of the format writing to w
+func (f Format) NewWriter(w io.Writer) Writer {
+	return f.newWriter(w)
+}
+
+// Result is the outcome of an export
+type Result struct {
+	Path string `json:"path"`
+	Rows int    `json:"rows"` // hands, groups or sessions written
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"aniki/internal/database"
+	"aniki/internal/hand_history"
+)
+
+// loadFixture parses a PokerStars hand history from testdata
+func loadFixture(t *testing.T, name string) []hand_history.Hand {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hands, err := hand_history.NewPokerStarsParser().ParseContent(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hands) == 0 {
+		t.Fatalf("no hands in %s", name)
+	}
+	return hands
+}
+
+// storedHand returns a parsed hand as the watcher stores it
+func storedHand(t *testing.T, hand hand_history.Hand) *database.Hand {
+	t.Helper()
+	encode := func(cards []string) string {
+		if len(cards) == 0 {
+			return ""
+		}
+		data, err := json.Marshal(cards)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	stored := &database.Hand{
+		Site:      &database.Site{Name: "PokerStars"},
+		HandID:    hand.HandID,
+		GameType:  hand.GameType,
+		Stakes:    hand.Stakes,
+		TableName: hand.TableName,
+		DateTime:  hand.DateTime,
+		HeroName:  hand.HeroName,
+		Position:  hand.Position,
+		HoleCards: encode(hand.HoleCards),
+		Board:     encode(hand.Board),
+		BigBlind:  hand.BigBlind,
+		Result:    hand.Result,
+		Rake:      hand.Rake,
+		TotalPot:  hand.TotalPot,
+		RawText:   hand.RawText,
+	}
+	for _, p := range hand.Players {
+		stored.Players = append(stored.Players, database.Player{
+			Name: p.Name, Seat: p.Seat, Stack: p.Stack, Position: p.Position, Cards: encode(p.Cards),
+		})
+	}
+	for _, a := range hand.Actions {
+		stored.Actions = append(stored.Actions, database.Action{
+			PlayerName: a.PlayerName, Action: a.Action, Amount: a.Amount, Street: a.Street, Sequence: a.Sequence, AllIn: a.AllIn,
+		})
+	}
+	return stored
+}
+
+// compareHands reports the differences between a hand and the same hand
+// parsed again after an export
+func compareHands(t *testing.T, want, got hand_history.Hand) {
+	t.Helper()
+	id := want.HandID
+	if got.HeroName != want.HeroName || !reflect.DeepEqual(got.HoleCards, want.HoleCards) {
+		t.Errorf("hand %s: hero %s %v, want %s %v", id, got.HeroName, got.HoleCards, want.HeroName, want.HoleCards)
+	}
+	if !reflect.DeepEqual(got.Players, want.Players) {
+		t.Errorf("hand %s: players\n got %+v\nwant %+v", id, got.Players, want.Players)
+	}
+	if !reflect.DeepEqual(got.Actions, want.Actions) {
+		t.Errorf("hand %s: actions\n got %+v\nwant %+v", id, got.Actions, want.Actions)
+	}
+	if !reflect.DeepEqual(got.Board, want.Board) {
+		t.Errorf("hand %s: board %v, want %v", id, got.Board, want.Board)
+	}
+	if got.TotalPot != want.TotalPot || got.Rake != want.Rake || got.Result != want.Result || got.BigBlind != want.BigBlind {
+		t.Errorf("hand %s: pot %v rake %v result %v big blind %v, want %v %v %v %v", id,
+			got.TotalPot, got.Rake, got.Result, got.BigBlind, want.TotalPot, want.Rake, want.Result, want.BigBlind)
+	}
+}
@@ -0,0 +1,242 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"aniki/internal/database"
+	"aniki/internal/hand_history"
+)
+
+// ohhWriter writes hands as Open Hand History documents separated by a
+// blank line
+type ohhWriter struct {
+	w *bufio.Writer
+}
+
+func newOHHWriter(w io.Writer) Writer {
+	return &ohhWriter{w: bufio.NewWriter(w)}
+}
+
+func (o *ohhWriter) WriteHand(hand *database.Hand) error {
+	var data []byte
+	if isOHH(hand.RawText) {
+		// Hands imported from OHH are written back as they were
+		data = []byte(hand.RawText)
+	} else {
+		doc, err := ToOHH(hand)
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("failed to encode hand %s: %w", hand.HandID, err)
+		}
+	}
+
+	if _, err := o.w.Write(data); err != nil {
+		return err
+	}
+	_, err := o.w.WriteString("\n\n")
+	return err
+}
+
+func (o *ohhWriter) Flush() error {
+	return o.w.Flush()
+}
+
+// isOHH reports whether raw is an Open Hand History document
+func isOHH(raw string) bool {
+	return hand_history.NewOHHParser().CanParse(raw)
+}
+
+// ToOHH maps a hand loaded with its site, players and actions to the Open
+// Hand History standard. Side pots are not told apart: the hand has one
+// pot with every player's winnings.
+func ToOHH(hand *database.Hand) (*hand_history.OHH, error) {
+	doc := &hand_history.OHH{Hand: hand_history.OHHHand{
+		SpecVersion:     hand_history.OHHSpecVersion,
+		InternalVersion: "aniki",
+		GameNumber:      hand.HandID,
+		StartDateUTC:    hand.DateTime,
+		TableName:       hand.TableName,
+		BigBlindAmount:  hand.BigBlind,
+		Tournament:      strings.Contains(hand.RawText, "Tournament #"),
+		Flags:           []string{},
+		Players:         []hand_history.OHHPlayer{},
+		Rounds:          []hand_history.OHHRound{},
+	}}
+	o := &doc.Hand
+
+	if hand.Site != nil {
+		o.SiteName = hand.Site.Name
+		o.NetworkName = hand.Site.Name
+	}
+	o.GameType, o.BetLimit.BetType = ohhGame(hand.GameType)
+	if strings.Contains(hand.Stakes, "$") {
+		o.Currency = "USD"
+	}
+
+	var holeCards, board []string
+	if err := decodeCards(hand.HoleCards, &holeCards); err != nil {
+		return nil, fmt.Errorf("hand %s: invalid hole cards: %w", hand.HandID, err)
+	}
+	if err := decodeCards(hand.Board, &board); err != nil {
+		return nil, fmt.Errorf("hand %s: invalid board: %w", hand.HandID, err)
+	}
+
+	ids := map[string]int{}
+	for i, player := range hand.Players {
+		ids[player.Name] = i
+		o.Players = append(o.Players, hand_history.OHHPlayer{
+			ID:            i,
+			Seat:          player.Seat,
+			Name:          player.Name,
+			Display:       player.Name,
+			StartingStack: player.Stack,
+		})
+		if player.Position == "BTN" {
+			o.DealerSeat = player.Seat
+		}
+		if player.Name == hand.HeroName {
+			id := i
+			o.HeroPlayerID = &id
+		}
+	}
+
+	o.TableSize = tableSizeOf(hand)
+
+	number := 0
+	add := func(round *hand_history.OHHRound, action hand_history.OHHAction) {
+		number++
+		action.ActionNumber = number
+		round.Actions = append(round.Actions, action)
+	}
+
+	streets := []struct {
+		name  string
+		key   string
+		cards []string
+	}{
+		{"Preflop", "preflop", nil},
+		{"Flop", "flop", boardCards(board, 0, 3)},
+		{"Turn", "turn", boardCards(board, 3, 4)},
+		{"River", "river", boardCards(board, 4, 5)},
+	}
+
+	for _, street := range streets {
+		round := hand_history.OHHRound{ID: len(o.Rounds), Street: street.name, Cards: street.cards, Actions: []hand_history.OHHAction{}}
+		dealt := street.key != "preflop"
+
+		for _, a := range hand.Actions {
+			if a.Street != street.key {
+				continue
+			}
+			id, ok := ids[a.PlayerName]
+			if !ok {
+				return nil, fmt.Errorf("hand %s: action by unknown player %s", hand.HandID, a.PlayerName)
+			}
+			name := hand_history.OHHActionName(a.Action)
+			if name == "" {
+				continue
+			}
+
+			// Hole cards are dealt once the blinds are in
+			if !dealt && !strings.HasPrefix(name, "Post") {
+				dealHoleCards(o, &round, holeCards, add)
+				dealt = true
+			}
+
+			switch name {
+			case "Post SB":
+				o.SmallBlindAmount = a.Amount
+			case "Post Ante":
+				o.AnteAmount = max(o.AnteAmount, a.Amount)
+			}
+			add(&round, hand_history.OHHAction{PlayerID: id, Action: name, Amount: a.Amount, IsAllIn: a.AllIn})
+		}
+		if !dealt {
+			dealHoleCards(o, &round, holeCards, add)
+		}
+
+		if len(round.Actions) == 0 && len(round.Cards) == 0 {
+			continue
+		}
+		o.Rounds = append(o.Rounds, round)
+	}
+
+	showdown := hand_history.OHHRound{ID: len(o.Rounds), Street: "Showdown", Actions: []hand_history.OHHAction{}}
+	for i, player := range hand.Players {
+		var cards []string
+		if err := decodeCards(player.Cards, &cards); err != nil {
+			return nil, fmt.Errorf("hand %s: invalid cards of %s: %w", hand.HandID, player.Name, err)
+		}
+		if len(cards) > 0 {
+			add(&showdown, hand_history.OHHAction{PlayerID: i, Action: "Shows Cards", Cards: cards})
+		}
+	}
+	if len(showdown.Actions) > 0 {
+		o.Rounds = append(o.Rounds, showdown)
+	}
+
+	pot := hand_history.OHHPot{Amount: hand.TotalPot, Rake: hand.Rake, PlayerWins: []hand_history.OHHWinner{}}
+	collected := winnings(hand)
+	for i, player := range hand.Players {
+		if amount := collected[player.Name]; amount > 0 {
+			pot.PlayerWins = append(pot.PlayerWins, hand_history.OHHWinner{PlayerID: i, WinAmount: amount})
+		}
+	}
+	o.Pots = []hand_history.OHHPot{pot}
+
+	return doc, nil
+}
+
+// dealHoleCards adds the hero's hole cards to round
+func dealHoleCards(o *hand_history.OHHHand, round *hand_history.OHHRound, cards []string, add func(*hand_history.OHHRound, hand_history.OHHAction)) {
+	if o.HeroPlayerID == nil || len(cards) == 0 {
+		return
+	}
+	add(round, hand_history.OHHAction{PlayerID: *o.HeroPlayerID, Action: "Dealt Cards", Cards: cards})
+}
+
+// ohhGame returns the OHH game type and bet type of a game type such as
+// "Hold'em No Limit"
+func ohhGame(gameType string) (game, limit string) {
+	switch {
+	case strings.Contains(gameType, "Omaha") && strings.Contains(gameType, "Hi/Lo"):
+		game = "OmahaHiLo"
+	case strings.Contains(gameType, "Omaha"):
+		game = "Omaha"
+	default:
+		game = "Holdem"
+	}
+
+	switch {
+	case strings.Contains(gameType, "No Limit"):
+		limit = "NL"
+	case strings.Contains(gameType, "Pot Limit"):
+		limit = "PL"
+	case strings.Contains(gameType, "Limit"):
+		limit = "FL"
+	}
+	return game, limit
+}
+
+// boardCards returns board[from:to], or nil when those cards were not dealt
+func boardCards(board []string, from, to int) []string {
+	if len(board) < to {
+		return nil
+	}
+	return board[from:to]
+}
+
+// decodeCards decodes a JSON array of cards, leaving cards untouched when
+// data is empty
+func decodeCards(data string, cards *[]string) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), cards)
+}
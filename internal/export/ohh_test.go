@@ -0,0 +1,38 @@
+package export
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"aniki/internal/hand_history"
+)
+
+func TestOHHRoundTrip(t *testing.T) {
+	for _, hand := range loadFixture(t, "pokerstars.txt") {
+		stored := storedHand(t, hand)
+		doc, err := ToOHH(stored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := hand_history.NewOHHParser().ParseContent(string(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parsed) != 1 {
+			t.Fatalf("hand %s: got %d hands back", hand.HandID, len(parsed))
+		}
+		compareHands(t, hand, parsed[0])
+
+		// Every player's winnings, not only the hero's result
+		want := hand_history.Collected(hand.RawText)
+		if got := winnings(storedHand(t, parsed[0])); !reflect.DeepEqual(got, want) {
+			t.Errorf("hand %s: winnings %v, want %v", hand.HandID, got, want)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"aniki/internal/database"
+	"aniki/internal/hand_history"
+)
+
+// anonymousHero replaces the hero's screen name in anonymized exports
+const anonymousHero = "Hero"
+
+// TextOptions changes how a hand is written as PokerStars text
+type TextOptions struct {
+	// Rename maps screen names to the names written instead
+	Rename map[string]string
+}
+
+// textWriter writes hands as PokerStars hand histories separated by blank
+// lines, optionally with the hero renamed
+type textWriter struct {
+	w         *bufio.Writer
+	anonymize bool
+}
+
+func newTextWriter(w io.Writer) Writer {
+	return &textWriter{w: bufio.NewWriter(w)}
+}
+
+func newAnonymizedTextWriter(w io.Writer) Writer {
+	return &textWriter{w: bufio.NewWriter(w), anonymize: true}
+}
+
+func (t *textWriter) WriteHand(hand *database.Hand) error {
+	var opts TextOptions
+	if t.anonymize && hand.HeroName != "" {
+		opts.Rename = map[string]string{hand.HeroName: anonymousHero}
+	}
+	text, err := PokerStarsText(hand, opts)
+	if err != nil {
+		return err
+	}
+	if _, err := t.w.WriteString(text); err != nil {
+		return err
+	}
+	_, err = t.w.WriteString("\n\n")
+	return err
+}
+
+func (t *textWriter) Flush() error {
+	return t.w.Flush()
+}
+
+// streetNames are the board streets in order with their PokerStars names
+var streetNames = []struct {
+	key, name string
+	cards     int // board cards dealt by the end of the street
+}{
+	{"flop", "Flop", 3},
+	{"turn", "Turn", 4},
+	{"river", "River", 5},
+}
+
+// PokerStarsText writes a hand loaded with its players and actions as a
+// PokerStars hand history, whatever site it was played on. The text is
+// regenerated from the stored actions, so it reads like PokerStars even
+// for hands imported from other formats.
+func PokerStarsText(hand *database.Hand, opts TextOptions) (string, error) {
+	var holeCards, board []string
+	if err := decodeCards(hand.HoleCards, &holeCards); err != nil {
+		return "", fmt.Errorf("hand %s: invalid hole cards: %w", hand.HandID, err)
+	}
+	if err := decodeCards(hand.Board, &board); err != nil {
+		return "", fmt.Errorf("hand %s: invalid board: %w", hand.HandID, err)
+	}
+
+	cash := strings.Contains(hand.Stakes, "$")
+	money := func(amount float64) string {
+		if cash {
+			return "$" + formatChips(amount)
+		}
+		return formatChips(amount)
+	}
+	name := func(player string) string {
+		if renamed, ok := opts.Rename[player]; ok {
+			return renamed
+		}
+		return player
+	}
+
+	players := append([]database.Player(nil), hand.Players...)
+	sort.Slice(players, func(i, j int) bool { return players[i].Seat < players[j].Seat })
+	actions := append([]database.Action(nil), hand.Actions...)
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].Sequence < actions[j].Sequence })
+
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+
+	stakes := hand.Stakes
+	if cash {
+		stakes += " USD"
+	}
+	buttonSeat := 0
+	for _, player := range players {
+		if player.Position == "BTN" {
+			buttonSeat = player.Seat
+		}
+	}
+	line("PokerStars Hand #%s:  %s (%s) - %s ET", numericHandID(hand.HandID), hand.GameType, stakes, hand.DateTime.Format("2006/01/02 15:04:05"))
+	line("Table '%s' %d-max Seat #%d is the button", hand.TableName, tableSizeOf(hand), buttonSeat)
+	for _, player := range players {
+		line("Seat %d: %s (%s in chips)", player.Seat, name(player.Name), money(player.Stack))
+	}
+
+	dealt := false
+	dealHoleCards := func() {
+		if dealt {
+			return
+		}
+		dealt = true
+		line("*** HOLE CARDS ***")
+		if hand.HeroName != "" && len(holeCards) > 0 {
+			line("Dealt to %s [%s]", name(hand.HeroName), strings.Join(holeCards, " "))
+		}
+	}
+
+	shown := 0 // board cards written so far
+	dealStreets := func(until int) {
+		for _, street := range streetNames {
+			if street.cards <= shown || street.cards > until || street.cards > len(board) {
+				continue
+			}
+			if shown == 0 {
+				line("*** %s *** [%s]", strings.ToUpper(street.name), strings.Join(board[:street.cards], " "))
+			} else {
+				line("*** %s *** [%s] [%s]", strings.ToUpper(street.name), strings.Join(board[:shown], " "), strings.Join(board[shown:street.cards], " "))
+			}
+			shown = street.cards
+		}
+	}
+
+	street := "preflop"
+	bets := map[string]float64{}
+	highest := 0.0
+	invested := map[string]float64{}
+	folded := map[string]string{}
+	parsed := make([]hand_history.Action, 0, len(actions))
+
+	for _, a := range actions {
+		if !strings.HasPrefix(a.Action, "posts") {
+			dealHoleCards()
+		}
+		if a.Street != street {
+			street = a.Street
+			bets = map[string]float64{}
+			highest = 0
+			for _, s := range streetNames {
+				if s.key == street {
+					dealStreets(s.cards)
+				}
+			}
+		}
+
+		text := ""
+		switch a.Action {
+		case "folds", "checks":
+		case "raises":
+			text = fmt.Sprintf(" %s to %s", money(a.Amount-highest), money(a.Amount))
+			invested[a.PlayerName] += a.Amount - bets[a.PlayerName]
+			bets[a.PlayerName] = a.Amount
+		default:
+			text = " " + money(a.Amount)
+			invested[a.PlayerName] += a.Amount
+			if a.Action != "posts the ante" {
+				bets[a.PlayerName] += a.Amount
+			}
+		}
+		highest = max(highest, bets[a.PlayerName])
+		if a.Action == "folds" {
+			folded[a.PlayerName] = a.Street
+		}
+		if a.AllIn {
+			text += " and is all-in"
+		}
+		line("%s: %s%s", name(a.PlayerName), a.Action, text)
+
+		parsed = append(parsed, hand_history.Action{PlayerName: a.PlayerName, Action: a.Action, Amount: a.Amount, Street: a.Street})
+	}
+	dealHoleCards()
+
+	returned := hand_history.UncalledBets(parsed)
+	for _, player := range players {
+		if amount := returned[player.Name]; amount > 0 {
+			line("Uncalled bet (%s) returned to %s", money(amount), name(player.Name))
+		}
+	}
+	dealStreets(len(board))
+
+	cards := map[string][]string{}
+	for _, player := range players {
+		var shownCards []string
+		if err := decodeCards(player.Cards, &shownCards); err != nil {
+			return "", fmt.Errorf("hand %s: invalid cards of %s: %w", hand.HandID, player.Name, err)
+		}
+		if len(shownCards) > 0 {
+			if len(cards) == 0 {
+				line("*** SHOW DOWN ***")
+			}
+			cards[player.Name] = shownCards
+			line("%s: shows [%s]%s", name(player.Name), strings.Join(shownCards, " "), madeHand(player.MadeHand))
+		}
+	}
+
+	won := winnings(hand)
+	for _, player := range players {
+		if amount := won[player.Name]; amount > 0 {
+			line("%s collected %s from pot", name(player.Name), money(amount))
+		}
+	}
+
+	line("*** SUMMARY ***")
+	line("Total pot %s | Rake %s", money(hand.TotalPot), money(hand.Rake))
+	if len(board) > 0 {
+		line("Board [%s]", strings.Join(board, " "))
+	}
+	for _, player := range players {
+		label := ""
+		switch player.Position {
+		case "BTN":
+			label = " (button)"
+			if len(players) == 2 {
+				label += " (small blind)"
+			}
+		case "SB":
+			label = " (small blind)"
+		case "BB":
+			label = " (big blind)"
+		}
+
+		outcome := "mucked"
+		switch {
+		case folded[player.Name] == "preflop":
+			outcome = "folded before Flop"
+			if invested[player.Name] == 0 {
+				outcome += " (didn't bet)"
+			}
+		case folded[player.Name] != "":
+			outcome = "folded on the " + strings.ToUpper(folded[player.Name][:1]) + folded[player.Name][1:]
+		case cards[player.Name] != nil && won[player.Name] > 0:
+			outcome = fmt.Sprintf("showed [%s] and won (%s)", strings.Join(cards[player.Name], " "), money(won[player.Name]))
+		case cards[player.Name] != nil:
+			outcome = fmt.Sprintf("showed [%s] and lost", strings.Join(cards[player.Name], " "))
+		case won[player.Name] > 0:
+			outcome = fmt.Sprintf("collected (%s)", money(won[player.Name]))
+		}
+		line("Seat %d: %s%s %s", player.Seat, name(player.Name), label, outcome)
+	}
+
+	return b.String(), nil
+}
+
+// madeHand returns the PokerStars wording of a made hand after the shown
+// cards, e.g. " (flush)"
+func madeHand(label string) string {
+	if label == "" {
+		return ""
+	}
+	return " (" + strings.ToLower(label) + ")"
+}
+
+// numericHandID returns the digits of a hand ID, as PokerStars hand
+// numbers are numeric. IDs without digits are replaced by a hash.
+func numericHandID(id string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, id)
+	if digits != "" {
+		return digits
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return fmt.Sprint(h.Sum32())
+}
+
+// tableSize matches the table size of a PokerStars table line
+var tableSize = regexp.MustCompile(`(\d+)-max`)
+
+// tableSizeOf returns the number of seats at the table of a hand, from
+// its hand history or else its highest occupied seat
+func tableSizeOf(hand *database.Hand) int {
+	if m := tableSize.FindStringSubmatch(hand.RawText); m != nil {
+		var size int
+		fmt.Sscan(m[1], &size)
+		return size
+	}
+	if isOHH(hand.RawText) {
+		var doc hand_history.OHH
+		if json.Unmarshal([]byte(hand.RawText), &doc) == nil && doc.Hand.TableSize > 0 {
+			return doc.Hand.TableSize
+		}
+	}
+	size := 0
+	for _, player := range hand.Players {
+		size = max(size, player.Seat)
+	}
+	return size
+}
+
+// winnings returns the chips each player won, read from the raw hand
+// history in whichever format it was imported
+func winnings(hand *database.Hand) map[string]float64 {
+	if !isOHH(hand.RawText) {
+		return hand_history.Collected(hand.RawText)
+	}
+
+	won := map[string]float64{}
+	var doc hand_history.OHH
+	if err := json.Unmarshal([]byte(hand.RawText), &doc); err != nil {
+		return won
+	}
+	names := map[int]string{}
+	for _, player := range doc.Hand.Players {
+		names[player.ID] = player.Name
+	}
+	for _, pot := range doc.Hand.Pots {
+		for _, win := range pot.PlayerWins {
+			won[names[win.PlayerID]] += win.WinAmount
+		}
+	}
+	return won
+}
+
+// formatChips formats an amount with two decimals, or none for whole
+// amounts, as PokerStars does
+func formatChips(amount float64) string {
+	cents := int64(amount*100 + 0.5)
+	if cents%100 == 0 {
+		return fmt.Sprint(cents / 100)
+	}
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
@@ -0,0 +1,66 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"aniki/internal/hand_history"
+)
+
+// reparse parses regenerated PokerStars text holding a single hand
+func reparse(t *testing.T, text string) hand_history.Hand {
+	t.Helper()
+	hands, err := hand_history.NewPokerStarsParser().ParseContent(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hands) != 1 {
+		t.Fatalf("got %d hands from\n%s", len(hands), text)
+	}
+	return hands[0]
+}
+
+func TestPokerStarsTextRoundTrip(t *testing.T) {
+	for _, hand := range loadFixture(t, "pokerstars.txt") {
+		text, err := PokerStarsText(storedHand(t, hand), TextOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := reparse(t, text)
+		compareHands(t, hand, got)
+		if got.HandID != hand.HandID || got.GameType != hand.GameType || got.Stakes != hand.Stakes ||
+			got.TableName != hand.TableName || !got.DateTime.Equal(hand.DateTime) {
+			t.Errorf("hand %s: header %s %s %s %s %v, want %s %s %s %s %v", hand.HandID,
+				got.HandID, got.GameType, got.Stakes, got.TableName, got.DateTime,
+				hand.HandID, hand.GameType, hand.Stakes, hand.TableName, hand.DateTime)
+		}
+	}
+}
+
+func TestPokerStarsTextAnonymized(t *testing.T) {
+	for _, hand := range loadFixture(t, "pokerstars.txt") {
+		var b strings.Builder
+		w := newAnonymizedTextWriter(&b)
+		if err := w.WriteHand(storedHand(t, hand)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		text := b.String()
+
+		if strings.Contains(text, hand.HeroName) {
+			t.Errorf("hand %s: hero's screen name %s written:\n%s", hand.HandID, hand.HeroName, text)
+		}
+
+		// The same hand, played by "Hero"
+		got := reparse(t, text)
+		if got.HeroName != anonymousHero {
+			t.Errorf("hand %s: hero %q, want %q", hand.HandID, got.HeroName, anonymousHero)
+		}
+		if got.Result != hand.Result || len(got.Actions) != len(hand.Actions) {
+			t.Errorf("hand %s: result %v with %d actions, want %v with %d", hand.HandID,
+				got.Result, len(got.Actions), hand.Result, len(hand.Actions))
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package export
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aniki/internal/database"
+	"aniki/internal/hand_history"
+)
+
+// ShareOptions changes how a hand is summarized for sharing
+type ShareOptions struct {
+	// BigBlinds writes amounts in big blinds instead of chips
+	BigBlinds bool `json:"big_blinds"`
+}
+
+// SharedHand is an anonymized hand summary ready to be posted for review
+type SharedHand struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+	Image    string `json:"image"` // PNG, base64 encoded
+}
+
+// Share summarizes a hand loaded with its players and actions with screen
+// names replaced by positions, as plain text, Markdown and a PNG image
+func Share(hand *database.Hand, opts ShareOptions) (*SharedHand, error) {
+	lines, err := summarize(hand, opts)
+	if err != nil {
+		return nil, err
+	}
+	image, err := renderPNG(lines)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render hand %s: %w", hand.HandID, err)
+	}
+	return &SharedHand{
+		Text:     renderText(lines),
+		Markdown: renderMarkdown(lines),
+		Image:    image,
+	}, nil
+}
+
+// Anonymize maps the screen names of a hand to their positions, the
+// hero's marked "(Hero)". Players without a position are named by seat.
+func Anonymize(hand *database.Hand) map[string]string {
+	names := make(map[string]string, len(hand.Players))
+	for _, player := range hand.Players {
+		name := player.Position
+		if name == "" {
+			name = fmt.Sprintf("Seat %d", player.Seat)
+		}
+		if player.Name == hand.HeroName {
+			name += " (Hero)"
+		}
+		names[player.Name] = name
+	}
+	return names
+}
+
+// Kinds of summary lines, styled differently by each rendering
+const (
+	lineTitle   = iota // the game and stakes
+	lineHeading        // a street, with the cards dealt on it
+	lineBody           // an action or outcome
+)
+
+// shareLine is a line of a hand summary
+type shareLine struct {
+	kind  int
+	text  string
+	cards []string
+}
+
+// summarize builds the lines of an anonymized hand summary
+func summarize(hand *database.Hand, opts ShareOptions) ([]shareLine, error) {
+	var holeCards, board []string
+	if err := decodeCards(hand.HoleCards, &holeCards); err != nil {
+		return nil, fmt.Errorf("hand %s: invalid hole cards: %w", hand.HandID, err)
+	}
+	if err := decodeCards(hand.Board, &board); err != nil {
+		return nil, fmt.Errorf("hand %s: invalid board: %w", hand.HandID, err)
+	}
+
+	names := Anonymize(hand)
+	money := amountFormatter(hand, opts)
+
+	players := append([]database.Player(nil), hand.Players...)
+	sort.Slice(players, func(i, j int) bool { return players[i].Seat < players[j].Seat })
+	actions := append([]database.Action(nil), hand.Actions...)
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].Sequence < actions[j].Sequence })
+
+	var lines []shareLine
+	add := func(kind int, text string, cards []string) {
+		lines = append(lines, shareLine{kind: kind, text: text, cards: cards})
+	}
+
+	add(lineTitle, fmt.Sprintf("%s %s, %d players", hand.GameType, hand.Stakes, len(players)), nil)
+	stacks := make([]string, 0, len(players))
+	for _, player := range players {
+		stacks = append(stacks, fmt.Sprintf("%s %s", names[player.Name], money(player.Stack)))
+	}
+	add(lineBody, "Stacks: "+strings.Join(stacks, ", "), nil)
+
+	pot := 0.0
+	bets := map[string]float64{}
+	shown := 0 // board cards dealt so far
+	dealStreets := func(until int) {
+		for _, street := range streetNames {
+			if street.cards <= shown || street.cards > until || street.cards > len(board) {
+				continue
+			}
+			add(lineHeading, fmt.Sprintf("%s (%s)", street.name, money(pot)), board[shown:street.cards])
+			shown = street.cards
+		}
+	}
+
+	add(lineHeading, "Preflop", holeCards)
+	street := "preflop"
+	parsed := make([]hand_history.Action, 0, len(actions))
+
+	for _, a := range actions {
+		if a.Street != street {
+			street = a.Street
+			bets = map[string]float64{}
+			for _, s := range streetNames {
+				if s.key == street {
+					dealStreets(s.cards)
+				}
+			}
+		}
+
+		text := a.Action
+		switch a.Action {
+		case "folds", "checks":
+		case "raises":
+			text += " to " + money(a.Amount)
+			pot += a.Amount - bets[a.PlayerName]
+			bets[a.PlayerName] = a.Amount
+		default:
+			text += " " + money(a.Amount)
+			pot += a.Amount
+			if a.Action != "posts the ante" {
+				bets[a.PlayerName] += a.Amount
+			}
+		}
+		if a.AllIn {
+			text += " and is all-in"
+		}
+		add(lineBody, names[a.PlayerName]+" "+text, nil)
+
+		parsed = append(parsed, hand_history.Action{PlayerName: a.PlayerName, Action: a.Action, Amount: a.Amount, Street: a.Street})
+	}
+
+	returned := hand_history.UncalledBets(parsed)
+	for _, amount := range returned {
+		pot -= amount
+	}
+	// Streets dealt once nobody was left to act
+	dealStreets(len(board))
+
+	add(lineHeading, "Result", nil)
+	for _, player := range players {
+		if amount := returned[player.Name]; amount > 0 {
+			add(lineBody, fmt.Sprintf("Uncalled %s returned to %s", money(amount), names[player.Name]), nil)
+		}
+	}
+	for _, player := range players {
+		var cards []string
+		if err := decodeCards(player.Cards, &cards); err != nil {
+			return nil, fmt.Errorf("hand %s: invalid cards of %s: %w", hand.HandID, player.Name, err)
+		}
+		if len(cards) > 0 {
+			add(lineBody, fmt.Sprintf("%s shows %s%s", names[player.Name], strings.Join(cards, " "), madeHand(player.MadeHand)), nil)
+		}
+	}
+	won := winnings(hand)
+	for _, player := range players {
+		if amount := won[player.Name]; amount > 0 {
+			add(lineBody, fmt.Sprintf("%s wins %s", names[player.Name], money(amount)), nil)
+		}
+	}
+	add(lineBody, fmt.Sprintf("Total pot %s, rake %s", money(hand.TotalPot), money(hand.Rake)), nil)
+
+	return lines, nil
+}
+
+// amountFormatter returns a function formatting amounts of a hand in chips,
+// or in big blinds when asked and the big blind is known
+func amountFormatter(hand *database.Hand, opts ShareOptions) func(float64) string {
+	if opts.BigBlinds && hand.BigBlind > 0 {
+		return func(amount float64) string {
+			bb := math.Round(amount/hand.BigBlind*100) / 100
+			return strconv.FormatFloat(bb, 'f', -1, 64) + " bb"
+		}
+	}
+	cash := strings.Contains(hand.Stakes, "$")
+	return func(amount float64) string {
+		if cash {
+			return "$" + formatChips(amount)
+		}
+		return formatChips(amount)
+	}
+}
+
+// renderText writes summary lines as plain text with a blank line before
+// each street
+func renderText(lines []shareLine) string {
+	var b strings.Builder
+	for _, line := range lines {
+		switch line.kind {
+		case lineHeading:
+			b.WriteString("\n")
+			b.WriteString(line.text)
+			if len(line.cards) > 0 {
+				b.WriteString(": " + strings.Join(line.cards, " "))
+			}
+		default:
+			b.WriteString(line.text)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderMarkdown writes summary lines as Markdown, each street in bold
+// followed by a list of its actions
+func renderMarkdown(lines []shareLine) string {
+	var b strings.Builder
+	for i, line := range lines {
+		switch line.kind {
+		case lineTitle:
+			b.WriteString("### " + line.text + "\n")
+		case lineHeading:
+			b.WriteString("\n**" + line.text + "**")
+			if len(line.cards) > 0 {
+				b.WriteString(": `" + strings.Join(line.cards, " ") + "`")
+			}
+			b.WriteString("\n")
+		default:
+			switch lines[i-1].kind {
+			case lineTitle:
+				b.WriteString("\n" + line.text + "\n")
+				continue
+			case lineHeading:
+				b.WriteString("\n")
+			}
+			b.WriteString("- " + line.text + "\n")
+		}
+	}
+	return b.String()
+}
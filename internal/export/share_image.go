@@ -0,0 +1,131 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Layout of rendered hand summaries
+const (
+	imageColumns = 64 // characters per row before wrapping
+	imageMargin  = 12
+	imageSpacing = 3 // pixels between rows
+	imageScale   = 2 // the fixed font is drawn small and scaled up
+)
+
+// Colours of rendered hand summaries
+var (
+	imageBackground = color.RGBA{0x1f, 0x29, 0x37, 0xff}
+	imageColors     = map[int]color.Color{
+		lineTitle:   color.RGBA{0xfa, 0xcc, 0x15, 0xff},
+		lineHeading: color.RGBA{0xc0, 0x84, 0xfc, 0xff},
+		lineBody:    color.RGBA{0xe5, 0xe7, 0xeb, 0xff},
+	}
+	suitColors = map[byte]color.Color{
+		'h': color.RGBA{0xf8, 0x71, 0x71, 0xff},
+		'd': color.RGBA{0x38, 0xbd, 0xf8, 0xff},
+		'c': color.RGBA{0x4a, 0xde, 0x80, 0xff},
+		's': color.RGBA{0x9c, 0xa3, 0xaf, 0xff},
+	}
+)
+
+// imageWord is a word drawn in a colour
+type imageWord struct {
+	text  string
+	color color.Color
+}
+
+// renderPNG draws summary lines as a PNG image, with cards coloured by
+// suit, and returns it base64 encoded
+func renderPNG(lines []shareLine) (string, error) {
+	var rows [][]imageWord
+	for i, line := range lines {
+		if line.kind == lineHeading && i > 0 {
+			rows = append(rows, nil)
+		}
+		words := strings.Fields(line.text)
+		if len(line.cards) > 0 {
+			words[len(words)-1] += ":"
+			words = append(words, line.cards...)
+		}
+		rows = append(rows, wrapWords(words, imageColors[line.kind])...)
+	}
+
+	face := basicfont.Face7x13
+	advance := face.Advance
+	rowHeight := face.Height + imageSpacing
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, rowLength(row))
+	}
+
+	small := image.NewRGBA(image.Rect(0, 0, 2*imageMargin+columns*advance, 2*imageMargin+len(rows)*rowHeight))
+	draw.Draw(small, small.Bounds(), image.NewUniform(imageBackground), image.Point{}, draw.Src)
+
+	d := &font.Drawer{Dst: small, Face: face}
+	for i, row := range rows {
+		x := imageMargin
+		y := imageMargin + i*rowHeight + face.Ascent
+		for _, word := range row {
+			d.Src = image.NewUniform(word.color)
+			d.Dot = fixed.P(x, y)
+			d.DrawString(word.text)
+			x += (len(word.text) + 1) * advance
+		}
+	}
+
+	bounds := small.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*imageScale, bounds.Dy()*imageScale))
+	draw.NearestNeighbor.Scale(scaled, scaled.Bounds(), small, bounds, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaled); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// wrapWords lays words out in rows of at most imageColumns characters,
+// continuation rows indented, colouring cards by suit
+func wrapWords(words []string, base color.Color) [][]imageWord {
+	var rows [][]imageWord
+	var row []imageWord
+	for _, word := range words {
+		if len(row) > 0 && rowLength(row)+1+len(word) > imageColumns {
+			rows = append(rows, row)
+			row = []imageWord{{text: " ", color: base}}
+		}
+		c := base
+		if isCard(word) {
+			c = suitColors[word[1]]
+		}
+		row = append(row, imageWord{text: word, color: c})
+	}
+	return append(rows, row)
+}
+
+// rowLength returns the number of characters of a row of words
+func rowLength(row []imageWord) int {
+	n := 0
+	for i, word := range row {
+		if i > 0 {
+			n++
+		}
+		n += len(word.text)
+	}
+	return n
+}
+
+// isCard reports whether word is a card such as "Ah"
+func isCard(word string) bool {
+	return len(word) == 2 && strings.IndexByte("23456789TJQKA", word[0]) >= 0 && suitColors[word[1]] != nil
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"testing"
+
+	"aniki/internal/database"
+)
+
+func TestShareHidesNames(t *testing.T) {
+	for _, hand := range loadFixture(t, "pokerstars.txt") {
+		stored := storedHand(t, hand)
+		shared, err := Share(stored, ShareOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		image, err := base64.StdEncoding.DecodeString(shared.Image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := png.Decode(bytes.NewReader(image)); err != nil {
+			t.Fatalf("hand %s: invalid PNG: %v", hand.HandID, err)
+		}
+		// The image only draws the summary lines, so they must not name
+		// anyone either
+		lines, err := summarize(stored, ShareOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var drawn strings.Builder
+		for _, line := range lines {
+			drawn.WriteString(line.text + "\n")
+		}
+
+		outputs := map[string]string{
+			"text":      shared.Text,
+			"markdown":  shared.Markdown,
+			"png":       string(image),
+			"png lines": drawn.String(),
+		}
+		for _, player := range hand.Players {
+			for kind, out := range outputs {
+				if strings.Contains(out, player.Name) {
+					t.Errorf("hand %s: %s names %s", hand.HandID, kind, player.Name)
+				}
+			}
+		}
+		if !strings.Contains(shared.Text, "BTN (Hero)") && !strings.Contains(shared.Text, "BB (Hero)") {
+			t.Errorf("hand %s: hero not shown by position:\n%s", hand.HandID, shared.Text)
+		}
+	}
+}
+
+func TestShareBigBlinds(t *testing.T) {
+	hand := storedHand(t, loadFixture(t, "pokerstars.txt")[0])
+	shared, err := Share(hand, ShareOptions{BigBlinds: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"SB 100 bb", "BB 80.5 bb", "BTN (Hero) raises to 3 bb", "Total pot 34.5 bb, rake 1 bb"} {
+		if !strings.Contains(shared.Text, want) {
+			t.Errorf("missing %q in\n%s", want, shared.Text)
+		}
+	}
+
+	tests := []struct {
+		bigBlind, amount float64
+		want             string
+	}{
+		{2, 5, "2.5 bb"},
+		{0.25, 1, "4 bb"},
+		{0.02, 0.01, "0.5 bb"},
+		{3, 1, "0.33 bb"},
+		// Without a big blind amounts stay in chips
+		{0, 5, "$5"},
+	}
+	for _, tt := range tests {
+		format := amountFormatter(&database.Hand{Stakes: "$1/$2", BigBlind: tt.bigBlind}, ShareOptions{BigBlinds: true})
+		if got := format(tt.amount); got != tt.want {
+			t.Errorf("%v with a %v big blind: %q, want %q", tt.amount, tt.bigBlind, got, tt.want)
+		}
+	}
+}
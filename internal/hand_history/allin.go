@@ -0,0 +1,219 @@
+package hand_history
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sync"
+
+	"aniki/internal/poker"
+)
+
+// equityCacheSize bounds the number of cached all-in equities
+const equityCacheSize = 4096
+
+// equityCache remembers calculated all-in equities. The watcher parses a
+// whole file again whenever a hand is appended, so without it the all-ins
+// of earlier hands would be enumerated on every new hand.
+var equityCache = struct {
+	sync.Mutex
+	shares map[string][]float64
+}{shares: map[string][]float64{}}
+
+// cachedEquity returns poker.Equity, calculating it only once per game,
+// hands and board
+func cachedEquity(gameType string, hands [][]poker.Card, board []poker.Card) ([]float64, error) {
+	key := fmt.Sprint(gameType, hands, board)
+
+	equityCache.Lock()
+	shares, ok := equityCache.shares[key]
+	equityCache.Unlock()
+	if ok {
+		return shares, nil
+	}
+
+	shares, err := poker.Equity(gameType, hands, board)
+	if err != nil {
+		return nil, err
+	}
+
+	equityCache.Lock()
+	if len(equityCache.shares) >= equityCacheSize {
+		equityCache.shares = map[string][]float64{}
+	}
+	equityCache.shares[key] = shares
+	equityCache.Unlock()
+	return shares, nil
+}
+
+// boardSizes is the number of board cards dealt by the end of each street
+var boardSizes = map[string]int{"preflop": 0, "flop": 3, "turn": 4}
+
+// investments returns the chips each player put into the pot. Raises are
+// recorded as the street total ("raises 4 to 6"), so they replace what the
+// player had already put in on that street. Uncalled bets are given back.
+func investments(actions []Action, returned map[string]float64) map[string]float64 {
+	total := map[string]float64{}
+	street := map[string]float64{}
+	current := ""
+
+	for _, a := range actions {
+		if a.Street != current {
+			current = a.Street
+			street = map[string]float64{}
+		}
+
+		switch a.Action {
+		case "posts the ante":
+			total[a.PlayerName] += a.Amount
+		case "raises":
+			total[a.PlayerName] += a.Amount - street[a.PlayerName]
+			street[a.PlayerName] = a.Amount
+		case "posts small blind", "posts big blind", "calls", "bets":
+			total[a.PlayerName] += a.Amount
+			street[a.PlayerName] += a.Amount
+		}
+	}
+
+	for name, amount := range returned {
+		total[name] -= amount
+	}
+	return total
+}
+
+// pot is a main or side pot and the players who can win it
+type pot struct {
+	amount   float64
+	eligible []string
+}
+
+// sidePots splits the chips invested into a main pot and side pots by
+// contribution level. Every player's chips count towards the pots, but only
+// the players in live, who have not folded, can win them. Pots are
+// returned from the main pot up.
+func sidePots(invested map[string]float64, live []string) []pot {
+	var levels []float64
+	for _, name := range live {
+		level := math.Round(invested[name]*100) / 100
+		if level > 0 && !slices.Contains(levels, level) {
+			levels = append(levels, level)
+		}
+	}
+	slices.Sort(levels)
+
+	var pots []pot
+	previous := 0.0
+	for _, level := range levels {
+		p := pot{}
+		for _, amount := range invested {
+			p.amount += max(min(amount, level)-previous, 0)
+		}
+		for _, name := range live {
+			if invested[name] >= level-0.005 {
+				p.eligible = append(p.eligible, name)
+			}
+		}
+		pots = append(pots, p)
+		previous = level
+	}
+	return pots
+}
+
+// allInEV sets the hero's all-in equity and EV when the betting ended with
+// a player all-in before the river and every player still in the hand
+// showed their cards. Each pot the hero can win is shared by the hero's
+// equity against the other players eligible for it, and the rake is taken
+// from the pots in proportion. The equity is the hero's expected share of
+// all the pots.
+func allInEV(hand *Hand, invested map[string]float64) {
+	if len(hand.HoleCards) == 0 || len(hand.Board) != 5 || len(hand.Actions) == 0 {
+		return
+	}
+
+	// Betting must have ended before the river with someone all-in
+	last := hand.Actions[len(hand.Actions)-1]
+	boardSize, ok := boardSizes[last.Street]
+	if !ok {
+		return
+	}
+
+	allIn := false
+	folded := map[string]bool{}
+	inHand := map[string]bool{}
+	for _, a := range hand.Actions {
+		inHand[a.PlayerName] = true
+		if a.Action == "folds" {
+			folded[a.PlayerName] = true
+		}
+		if a.AllIn {
+			allIn = true
+		}
+	}
+	if !allIn || folded[hand.HeroName] {
+		return
+	}
+
+	// Every player still in the hand other than the hero must have shown
+	hero, err := poker.ParseCards(hand.HoleCards)
+	if err != nil {
+		return
+	}
+	cards := map[string][]poker.Card{hand.HeroName: hero}
+	live := []string{hand.HeroName}
+	for _, player := range hand.Players {
+		if player.Name == hand.HeroName || !inHand[player.Name] || folded[player.Name] {
+			continue
+		}
+		if len(player.Cards) == 0 {
+			return
+		}
+		shown, err := poker.ParseCards(player.Cards)
+		if err != nil {
+			return
+		}
+		cards[player.Name] = shown
+		live = append(live, player.Name)
+	}
+	if len(live) < 2 {
+		return
+	}
+
+	board, err := poker.ParseCards(hand.Board[:boardSize])
+	if err != nil {
+		return
+	}
+
+	// The hero comes first in each pot's equity calculation
+	var total, won float64
+	for _, p := range sidePots(invested, live) {
+		total += p.amount
+		if !slices.Contains(p.eligible, hand.HeroName) {
+			continue
+		}
+		if len(p.eligible) == 1 {
+			won += p.amount
+			continue
+		}
+		hands := [][]poker.Card{hero}
+		for _, name := range p.eligible {
+			if name != hand.HeroName {
+				hands = append(hands, cards[name])
+			}
+		}
+		shares, err := cachedEquity(hand.GameType, hands, board)
+		if err != nil {
+			log.Printf("Failed to calculate all-in equity of hand %s: %v", hand.HandID, err)
+			return
+		}
+		won += shares[0] * p.amount
+	}
+	if total <= 0 {
+		return
+	}
+
+	equity := won / total
+	ev := equity*(hand.TotalPot-hand.Rake) - invested[hand.HeroName]
+	hand.AllInEquity = &equity
+	hand.AllInEV = &ev
+}
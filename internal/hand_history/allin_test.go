@@ -0,0 +1,53 @@
+package hand_history
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSidePots(t *testing.T) {
+	invested := map[string]float64{"Hero": 50, "Alice": 100, "Bob": 100, "Carol": 10}
+	got := sidePots(invested, []string{"Hero", "Alice", "Bob"})
+	want := []pot{
+		{amount: 160, eligible: []string{"Hero", "Alice", "Bob"}},
+		{amount: 100, eligible: []string{"Alice", "Bob"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAllInEVSidePot(t *testing.T) {
+	// Hero's quads win the main pot whatever the river, but Hero cannot
+	// win the side pot Alice and Bob play for
+	hand := &Hand{
+		HandID:    "1",
+		GameType:  "Hold'em No Limit",
+		HeroName:  "Hero",
+		HoleCards: []string{"Ah", "As"},
+		Board:     []string{"Ad", "Ac", "2h", "3s", "Kh"},
+		TotalPot:  250,
+		Players: []Player{
+			{Name: "Hero", Seat: 1},
+			{Name: "Alice", Seat: 2, Cards: []string{"Kd", "Kc"}},
+			{Name: "Bob", Seat: 3, Cards: []string{"Qd", "Qc"}},
+		},
+		Actions: []Action{
+			{PlayerName: "Hero", Action: "bets", Amount: 50, Street: "turn", AllIn: true},
+			{PlayerName: "Alice", Action: "raises", Amount: 100, Street: "turn"},
+			{PlayerName: "Bob", Action: "calls", Amount: 100, Street: "turn", AllIn: true},
+		},
+	}
+
+	allInEV(hand, investments(hand.Actions, nil))
+	if hand.AllInEquity == nil {
+		t.Fatal("no all-in equity")
+	}
+	if want := 150.0 / 250; math.Abs(*hand.AllInEquity-want) > 1e-9 {
+		t.Errorf("equity %v, want %v", *hand.AllInEquity, want)
+	}
+	if want := 100.0; math.Abs(*hand.AllInEV-want) > 1e-9 {
+		t.Errorf("EV %v, want %v", *hand.AllInEV, want)
+	}
+}
@@ -0,0 +1,358 @@
+package hand_history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OHHSpecVersion is the Open Hand History version written by Aniki
+const OHHSpecVersion = "1.4.7"
+
+// OHH is one hand in the Open Hand History JSON standard
+// (https://hh-specs.handhistory.org), wrapped as {"ohh": {...}}
+type OHH struct {
+	Hand OHHHand `json:"ohh"`
+}
+
+// OHHHand is the body of an Open Hand History document
+type OHHHand struct {
+	SpecVersion      string      `json:"spec_version"`
+	SiteName         string      `json:"site_name"`
+	NetworkName      string      `json:"network_name"`
+	InternalVersion  string      `json:"internal_version"`
+	Tournament       bool        `json:"tournament"`
+	GameNumber       string      `json:"game_number"`
+	StartDateUTC     time.Time   `json:"start_date_utc"`
+	TableName        string      `json:"table_name"`
+	GameType         string      `json:"game_type"` // Holdem, Omaha or OmahaHiLo
+	BetLimit         OHHBetLimit `json:"bet_limit"`
+	TableSize        int         `json:"table_size"`
+	Currency         string      `json:"currency"`
+	DealerSeat       int         `json:"dealer_seat"`
+	SmallBlindAmount float64     `json:"small_blind_amount"`
+	BigBlindAmount   float64     `json:"big_blind_amount"`
+	AnteAmount       float64     `json:"ante_amount"`
+	HeroPlayerID     *int        `json:"hero_player_id,omitempty"`
+	Flags            []string    `json:"flags"`
+	Players          []OHHPlayer `json:"players"`
+	Rounds           []OHHRound  `json:"rounds"`
+	Pots             []OHHPot    `json:"pots"`
+}
+
+// OHHBetLimit is the betting structure: NL, PL or FL
+type OHHBetLimit struct {
+	BetType string  `json:"bet_type"`
+	BetCap  float64 `json:"bet_cap"`
+}
+
+// OHHPlayer is a player seated at the start of the hand
+type OHHPlayer struct {
+	ID            int     `json:"id"`
+	Seat          int     `json:"seat"`
+	Name          string  `json:"name"`
+	Display       string  `json:"display,omitempty"`
+	StartingStack float64 `json:"starting_stack"`
+}
+
+// OHHRound is a street with the cards dealt to the board and its actions
+type OHHRound struct {
+	ID      int         `json:"id"`
+	Street  string      `json:"street"` // Preflop, Flop, Turn, River or Showdown
+	Cards   []string    `json:"cards,omitempty"`
+	Actions []OHHAction `json:"actions"`
+}
+
+// OHHAction is a player action. The amount of a raise is the total the
+// player raised to on the street; other amounts are the chips added.
+type OHHAction struct {
+	ActionNumber int      `json:"action_number"`
+	PlayerID     int      `json:"player_id"`
+	Action       string   `json:"action"`
+	Amount       float64  `json:"amount,omitempty"`
+	IsAllIn      bool     `json:"is_allin"`
+	Cards        []string `json:"cards,omitempty"`
+}
+
+// OHHPot is a pot with the rake taken from it and its winners
+type OHHPot struct {
+	Number     int         `json:"number"`
+	Amount     float64     `json:"amount"`
+	Rake       float64     `json:"rake"`
+	PlayerWins []OHHWinner `json:"player_wins"`
+}
+
+// OHHWinner is a player's share of a pot
+type OHHWinner struct {
+	PlayerID  int     `json:"player_id"`
+	WinAmount float64 `json:"win_amount"`
+}
+
+// OHH action names and the Aniki actions they correspond to
+var ohhActions = map[string]string{
+	"Post Ante": "posts the ante",
+	"Post SB":   "posts small blind",
+	"Post BB":   "posts big blind",
+	"Fold":      "folds",
+	"Check":     "checks",
+	"Call":      "calls",
+	"Bet":       "bets",
+	"Raise":     "raises",
+}
+
+// OHHActionName returns the OHH name of an Aniki action, or "" if OHH has none
+func OHHActionName(action string) string {
+	for name, a := range ohhActions {
+		if a == action {
+			return name
+		}
+	}
+	return ""
+}
+
+// OHH streets and the Aniki streets they correspond to
+var ohhStreets = map[string]string{
+	"Preflop":  "preflop",
+	"Flop":     "flop",
+	"Turn":     "turn",
+	"River":    "river",
+	"Showdown": "showdown",
+}
+
+// OHHParser parses Open Hand History files, one or more JSON documents.
+// Unlike site parsers, each hand names its own site.
+type OHHParser struct{}
+
+// NewOHHParser creates a new Open Hand History parser
+func NewOHHParser() *OHHParser {
+	return &OHHParser{}
+}
+
+// GetSiteName returns "ohh". Hands carry the name of the site they were
+// played on in Hand.Site.
+func (p *OHHParser) GetSiteName() string {
+	return "ohh"
+}
+
+// CanParse checks if the content is Open Hand History JSON
+func (p *OHHParser) CanParse(content string) bool {
+	return strings.HasPrefix(strings.TrimSpace(content), "{") && strings.Contains(content, `"ohh"`)
+}
+
+// ParseFile parses an Open Hand History file
+func (p *OHHParser) ParseFile(path string) ([]Hand, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return p.ParseContent(string(content))
+}
+
+// ParseContent parses Open Hand History documents, separated by
+// whitespace as in .ohh files
+func (p *OHHParser) ParseContent(content string) ([]Hand, error) {
+	var hands []Hand
+	decoder := json.NewDecoder(strings.NewReader(content))
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return hands, fmt.Errorf("invalid OHH document after %d hands: %w", len(hands), err)
+		}
+
+		var doc OHH
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return hands, fmt.Errorf("invalid OHH hand after %d hands: %w", len(hands), err)
+		}
+
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return hands, fmt.Errorf("invalid OHH hand after %d hands: %w", len(hands), err)
+		}
+
+		hand, err := doc.Hand.toHand()
+		if err != nil {
+			return hands, fmt.Errorf("OHH hand %s: %w", doc.Hand.GameNumber, err)
+		}
+		hand.RawText = compact.String()
+		hands = append(hands, *hand)
+	}
+	return hands, nil
+}
+
+// toHand converts an OHH hand into a parsed hand
+func (o *OHHHand) toHand() (*Hand, error) {
+	hand := &Hand{
+		HandID:    o.GameNumber,
+		Site:      o.SiteName,
+		GameType:  ohhGameType(o.GameType, o.BetLimit.BetType),
+		Stakes:    ohhStakes(o.Currency, o.SmallBlindAmount, o.BigBlindAmount),
+		TableName: o.TableName,
+		DateTime:  o.StartDateUTC,
+		BigBlind:  o.BigBlindAmount,
+		Actions:   []Action{},
+		Players:   []Player{},
+	}
+	if hand.HandID == "" {
+		return nil, errors.New("missing game number")
+	}
+
+	names := map[int]string{}
+	for _, player := range o.Players {
+		names[player.ID] = player.Name
+		hand.Players = append(hand.Players, Player{
+			Name:  player.Name,
+			Seat:  player.Seat,
+			Stack: player.StartingStack,
+		})
+		if o.HeroPlayerID != nil && *o.HeroPlayerID == player.ID {
+			hand.HeroName = player.Name
+		}
+	}
+
+	rounds := append([]OHHRound(nil), o.Rounds...)
+	sort.SliceStable(rounds, func(i, j int) bool { return rounds[i].ID < rounds[j].ID })
+
+	for _, round := range rounds {
+		street, ok := ohhStreets[round.Street]
+		if !ok {
+			return nil, fmt.Errorf("unknown street %q", round.Street)
+		}
+		hand.Board = append(hand.Board, round.Cards...)
+
+		for _, a := range round.Actions {
+			name, ok := names[a.PlayerID]
+			if !ok {
+				return nil, fmt.Errorf("action by unknown player %d", a.PlayerID)
+			}
+
+			switch a.Action {
+			case "Dealt Cards":
+				if name == hand.HeroName {
+					hand.HoleCards = a.Cards
+				}
+				continue
+			case "Shows Cards":
+				for i := range hand.Players {
+					if hand.Players[i].Name == name {
+						hand.Players[i].Cards = a.Cards
+					}
+				}
+				continue
+			}
+
+			action, ok := ohhActions[a.Action]
+			if !ok {
+				continue // Sitting out, chat and other non-betting actions
+			}
+			hand.Actions = append(hand.Actions, Action{
+				PlayerName: name,
+				Action:     action,
+				Amount:     a.Amount,
+				Street:     street,
+				Sequence:   len(hand.Actions),
+				AllIn:      a.IsAllIn,
+			})
+		}
+	}
+
+	for _, pot := range o.Pots {
+		hand.TotalPot += pot.Amount
+		hand.Rake += pot.Rake
+		for _, win := range pot.PlayerWins {
+			if names[win.PlayerID] == hand.HeroName && hand.HeroName != "" {
+				hand.Result += win.WinAmount
+			}
+		}
+	}
+
+	finishHand(hand, o.DealerSeat, UncalledBets(hand.Actions))
+	return hand, nil
+}
+
+// UncalledBets returns the part of each street's largest bet that nobody
+// matched, which is given back to the bettor
+func UncalledBets(actions []Action) map[string]float64 {
+	returned := map[string]float64{}
+	street := map[string]float64{}
+	current := ""
+
+	settle := func() {
+		var top string
+		var first, second float64
+		for name, bet := range street {
+			if bet > first {
+				top, first, second = name, bet, first
+			} else if bet > second {
+				second = bet
+			}
+		}
+		// Rounded to cents so split calls don't leave float residue
+		if diff := math.Round((first-second)*100) / 100; diff > 0 {
+			returned[top] += diff
+		}
+	}
+
+	for _, a := range actions {
+		if a.Street != current {
+			settle()
+			current = a.Street
+			street = map[string]float64{}
+		}
+		switch a.Action {
+		case "raises":
+			street[a.PlayerName] = a.Amount
+		case "posts small blind", "posts big blind", "calls", "bets":
+			street[a.PlayerName] += a.Amount
+		}
+	}
+	settle()
+	return returned
+}
+
+// ohhGameType returns the game type in PokerStars wording, e.g.
+// "Hold'em No Limit"
+func ohhGameType(game, limit string) string {
+	switch game {
+	case "Holdem":
+		game = "Hold'em"
+	case "OmahaHiLo":
+		game = "Omaha Hi/Lo"
+	}
+	switch limit {
+	case "NL":
+		return game + " No Limit"
+	case "PL":
+		return game + " Pot Limit"
+	case "FL":
+		return game + " Limit"
+	}
+	return game
+}
+
+// ohhStakes returns the stakes in PokerStars wording, e.g. "$0.01/$0.02"
+func ohhStakes(currency string, sb, bb float64) string {
+	symbol := ""
+	if currency == "USD" {
+		symbol = "$"
+	}
+	return fmt.Sprintf("%s%s/%s%s", symbol, formatChips(sb), symbol, formatChips(bb))
+}
+
+// formatChips formats an amount with two decimals, or none for whole
+// amounts
+func formatChips(amount float64) string {
+	if amount == float64(int64(amount)) {
+		return fmt.Sprintf("%d", int64(amount))
+	}
+	return fmt.Sprintf("%.2f", amount)
+}
@@ -1,6 +1,8 @@
 package hand_history
 
 import (
+	"fmt"
+	"os"
 	"time"
 )
 
@@ -23,6 +25,7 @@ type Parser interface {
 type Hand struct {
 	HandID    string
 	SiteID    int
+	Site      string // site the hand was played on, when the format names it
 	GameType  string
 	Stakes    string
 	TableName string
@@ -33,10 +36,15 @@ type Hand struct {
 	Board     []string
 	Actions   []Action
 	Players   []Player
+	BigBlind  float64
 	Result    float64
 	Rake      float64
 	TotalPot  float64
 	RawText   string
+
+	// Set when the hero was all-in before the river against known cards
+	AllInEquity *float64 // hero's share of the pot at the time of the all-in
+	AllInEV     *float64 // result had the pot been split by equity
 }
 
 // Action represents a player action in a hand
@@ -46,6 +54,7 @@ type Action struct {
 	Amount     float64
 	Street     string // preflop, flop, turn, river
 	Sequence   int
+	AllIn      bool
 }
 
 // Player represents a player at the table
@@ -54,28 +63,37 @@ type Player struct {
 	Seat     int
 	Stack    float64
 	Position string
+	Cards    []string // hole cards shown at showdown
+	MadeHand string   // made-hand category of shown cards, e.g. "Flush"
 }
 
 // Manager manages multiple parsers for different poker sites
 type Manager struct {
-	parsers map[string]Parser
+	parsers []Parser // tried in registration order
 }
 
 // NewManager creates a new parser manager
 func NewManager() *Manager {
-	m := &Manager{
-		parsers: make(map[string]Parser),
-	}
+	m := &Manager{}
 
 	// Register parsers
 	m.Register(NewPokerStarsParser())
+	m.Register(NewOHHParser())
 
 	return m
 }
 
-// Register adds a parser to the manager
+// Register adds a parser to the manager, replacing the parser registered
+// for the same site, if any. Parsers are tried in the order they were
+// first registered.
 func (m *Manager) Register(parser Parser) {
-	m.parsers[parser.GetSiteName()] = parser
+	for i, p := range m.parsers {
+		if p.GetSiteName() == parser.GetSiteName() {
+			m.parsers[i] = parser
+			return
+		}
+	}
+	m.parsers = append(m.parsers, parser)
 }
 
 // ParseFile attempts to parse a file using all registered parsers
@@ -86,24 +104,16 @@ func (m *Manager) ParseFile(path string) ([]Hand, string, error) {
 		return nil, "", err
 	}
 
-	// Try each parser
-	for siteName, parser := range m.parsers {
-		if parser.CanParse(content) {
-			hands, err := parser.ParseContent(content)
-			return hands, siteName, err
-		}
-	}
-
-	return nil, "", nil // No parser found
+	return m.ParseContent(content)
 }
 
 // ParseContent attempts to parse content using all registered parsers
 func (m *Manager) ParseContent(content string) ([]Hand, string, error) {
 	// Try each parser
-	for siteName, parser := range m.parsers {
+	for _, parser := range m.parsers {
 		if parser.CanParse(content) {
 			hands, err := parser.ParseContent(content)
-			return hands, siteName, err
+			return hands, parser.GetSiteName(), err
 		}
 	}
 
@@ -112,6 +122,9 @@ func (m *Manager) ParseContent(content string) ([]Hand, string, error) {
 
 // readFileContent reads the entire file content
 func readFileContent(path string) (string, error) {
-	// Implementation will use os.ReadFile
-	return "", nil
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return string(data), nil
 }
@@ -8,18 +8,24 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"aniki/internal/poker"
 )
 
 // PokerStarsParser parses PokerStars hand history files
 type PokerStarsParser struct {
 	handStart  *regexp.Regexp
 	gameInfo   *regexp.Regexp
+	cashGame   *regexp.Regexp
 	tableInfo  *regexp.Regexp
+	button     *regexp.Regexp
 	playerInfo *regexp.Regexp
 	actionLine *regexp.Regexp
 	holeCards  *regexp.Regexp
+	shownCards *regexp.Regexp
 	boardLine  *regexp.Regexp
 	potLine    *regexp.Regexp
+	uncalled   *regexp.Regexp
 	dateTime   *regexp.Regexp
 }
 
@@ -28,12 +34,16 @@ func NewPokerStarsParser() *PokerStarsParser {
 	return &PokerStarsParser{
 		handStart:  regexp.MustCompile(`PokerStars Hand #(\d+):`),
 		gameInfo:   regexp.MustCompile(`:\s+(.+?)\s+-\s+Level`),
+		cashGame:   regexp.MustCompile(`:\s+(.+?)\s+\(\$?[\d.]+/\$?[\d.]+`),
 		tableInfo:  regexp.MustCompile(`Table '([^']+)'\s+(\d+)-max`),
-		playerInfo: regexp.MustCompile(`Seat (\d+): ([^\(]+) \((\d+(?:\.\d+)?)\s+in chips\)`),
-		actionLine: regexp.MustCompile(`^([^:]+):\s+(folds|checks|calls|bets|raises|posts small blind|posts big blind|posts the ante)\s*(\d+(?:\.\d+)?)?\s*(?:to\s+(\d+(?:\.\d+)?))?`),
+		button:     regexp.MustCompile(`Seat #(\d+) is the button`),
+		playerInfo: regexp.MustCompile(`Seat (\d+): ([^\(]+) \(\$?(\d+(?:\.\d+)?)\s+in chips\)`),
+		actionLine: regexp.MustCompile(`^([^:]+):\s+(folds|checks|calls|bets|raises|posts small blind|posts big blind|posts the ante)\s*\$?(\d+(?:\.\d+)?)?\s*(?:to\s+\$?(\d+(?:\.\d+)?))?`),
 		holeCards:  regexp.MustCompile(`Dealt to ([^\[]+)\s+\[([^\]]+)\]`),
-		boardLine:  regexp.MustCompile(`\*\*\* (FLOP|TURN|RIVER) \*\*\*\s+\[([^\]]+)\]`),
-		potLine:    regexp.MustCompile(`Total pot (\d+(?:\.\d+)?)\s*(?:\|\s*Rake\s+(\d+(?:\.\d+)?))?`),
+		shownCards: regexp.MustCompile(`^([^:]+): shows \[([^\]]+)\]`),
+		boardLine:  regexp.MustCompile(`\*\*\* (FLOP|TURN|RIVER) \*\*\*\s+\[([^\]]+)\](?:\s+\[([^\]]+)\])?`),
+		potLine:    regexp.MustCompile(`Total pot \$?(\d+(?:\.\d+)?)\s*(?:\|\s*Rake\s+\$?(\d+(?:\.\d+)?))?`),
+		uncalled:   regexp.MustCompile(`^Uncalled bet \(\$?(\d+(?:\.\d+)?)\) returned to (.+)$`),
 		dateTime:   regexp.MustCompile(`(\d{4}/\d{2}/\d{2}) (\d{1,2}:\d{2}:\d{2})`),
 	}
 }
@@ -63,6 +73,8 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 	var currentHand *Hand
 	var currentStreet string
 	var actionSequence int
+	var buttonSeat int
+	var returned map[string]float64
 	var rawHandText strings.Builder
 
 	scanner := bufio.NewScanner(strings.NewReader(content))
@@ -75,6 +87,7 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 			// Save previous hand if exists
 			if currentHand != nil {
 				currentHand.RawText = rawHandText.String()
+				finishHand(currentHand, buttonSeat, returned)
 				hands = append(hands, *currentHand)
 			}
 
@@ -86,12 +99,16 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 			}
 			currentStreet = "preflop"
 			actionSequence = 0
+			buttonSeat = 0
+			returned = map[string]float64{}
 			rawHandText.Reset()
 			rawHandText.WriteString(line + "\n")
 
 			// Parse game type from the same line
 			if gameMatches := p.gameInfo.FindStringSubmatch(line); gameMatches != nil {
 				currentHand.GameType = strings.TrimSpace(gameMatches[1])
+			} else if gameMatches := p.cashGame.FindStringSubmatch(line); gameMatches != nil {
+				currentHand.GameType = strings.TrimSpace(gameMatches[1])
 			}
 
 			// Parse date/time
@@ -123,6 +140,9 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 		// Parse table info
 		if matches := p.tableInfo.FindStringSubmatch(line); matches != nil {
 			currentHand.TableName = matches[1]
+			if buttonMatches := p.button.FindStringSubmatch(line); buttonMatches != nil {
+				buttonSeat, _ = strconv.Atoi(buttonMatches[1])
+			}
 		}
 
 		// Parse player info
@@ -144,6 +164,16 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 			currentHand.HoleCards = cards
 		}
 
+		// Parse cards shown at showdown
+		if matches := p.shownCards.FindStringSubmatch(line); matches != nil {
+			name := strings.TrimSpace(matches[1])
+			for i := range currentHand.Players {
+				if currentHand.Players[i].Name == name {
+					currentHand.Players[i].Cards = strings.Fields(matches[2])
+				}
+			}
+		}
+
 		// Parse streets
 		if strings.Contains(line, "*** FLOP ***") {
 			currentStreet = "flop"
@@ -157,7 +187,8 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 
 		// Parse board cards
 		if matches := p.boardLine.FindStringSubmatch(line); matches != nil {
-			cards := strings.Fields(matches[2])
+			// Turn and river lines show the new card separately: [2c 3d 4h] [5s]
+			cards := append(strings.Fields(matches[2]), strings.Fields(matches[3])...)
 			currentHand.Board = cards
 		}
 
@@ -181,11 +212,21 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 				Amount:     amount,
 				Street:     currentStreet,
 				Sequence:   actionSequence,
+				AllIn:      strings.Contains(line, "and is all-in"),
+			}
+			if actionType == "posts big blind" && amount > currentHand.BigBlind {
+				currentHand.BigBlind = amount
 			}
 			currentHand.Actions = append(currentHand.Actions, action)
 			actionSequence++
 		}
 
+		// Parse uncalled bets, which do not count as invested
+		if matches := p.uncalled.FindStringSubmatch(line); matches != nil {
+			amount, _ := strconv.ParseFloat(matches[1], 64)
+			returned[strings.TrimSpace(matches[2])] += amount
+		}
+
 		// Parse pot and rake
 		if matches := p.potLine.FindStringSubmatch(line); matches != nil {
 			pot, _ := strconv.ParseFloat(matches[1], 64)
@@ -200,7 +241,7 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 		// Calculate result for hero
 		if currentHand.HeroName != "" && strings.Contains(line, currentHand.HeroName) {
 			if strings.Contains(line, "collected") {
-				collectRegex := regexp.MustCompile(`collected (\d+(?:\.\d+)?)`)
+				collectRegex := regexp.MustCompile(`collected \$?(\d+(?:\.\d+)?)`)
 				if collectMatches := collectRegex.FindStringSubmatch(line); collectMatches != nil {
 					collected, _ := strconv.ParseFloat(collectMatches[1], 64)
 					currentHand.Result += collected
@@ -212,18 +253,93 @@ func (p *PokerStarsParser) ParseContent(content string) ([]Hand, error) {
 	// Don't forget the last hand
 	if currentHand != nil {
 		currentHand.RawText = rawHandText.String()
+		finishHand(currentHand, buttonSeat, returned)
+		hands = append(hands, *currentHand)
+	}
 
-		// Calculate final result (amount won minus amount invested)
-		invested := 0.0
-		for _, action := range currentHand.Actions {
-			if action.PlayerName == currentHand.HeroName && action.Amount > 0 {
-				invested += action.Amount
-			}
+	return hands, scanner.Err()
+}
+
+// finishHand fills in the fields that need the whole hand: the hero's net
+// result, the big blind, table positions, showdown hands and all-in EV.
+// returned holds uncalled bets given back to each player.
+func finishHand(hand *Hand, buttonSeat int, returned map[string]float64) {
+	// Calculate final result (amount won minus amount invested)
+	invested := investments(hand.Actions, returned)
+	hand.Result -= invested[hand.HeroName]
+
+	if hand.BigBlind == 0 {
+		hand.BigBlind = StakesBigBlind(hand.Stakes)
+	}
+
+	assignPositions(hand.Players, buttonSeat)
+	labelShowdown(hand)
+	allInEV(hand, invested)
+	for _, player := range hand.Players {
+		if player.Name == hand.HeroName {
+			hand.Position = player.Position
+		}
+	}
+}
+
+// labelShowdown sets the made-hand category of every player who showed
+// cards
+func labelShowdown(hand *Hand) {
+	board, err := poker.ParseCards(hand.Board)
+	if err != nil {
+		return
+	}
+	for i := range hand.Players {
+		player := &hand.Players[i]
+		if len(player.Cards) == 0 {
+			continue
 		}
-		currentHand.Result -= invested
+		hole, err := poker.ParseCards(player.Cards)
+		if err != nil {
+			continue
+		}
+		player.MadeHand = poker.MadeHand(hand.GameType, hole, board)
+	}
+}
 
-		hands = append(hands, *currentHand)
+// assignPositions labels players by their seat relative to the button
+func assignPositions(players []Player, buttonSeat int) {
+	seats := make([]int, len(players))
+	for i, player := range players {
+		seats[i] = player.Seat
 	}
+	for i, position := range poker.Positions(seats, buttonSeat) {
+		players[i].Position = position
+	}
+}
 
-	return hands, scanner.Err()
+// collectedLine matches a player collecting a pot, e.g. "Hero collected
+// $2.98 from pot" or "Hero collected 1500 from side pot-1"
+var collectedLine = regexp.MustCompile(`^(.+?) collected \$?(\d+(?:\.\d+)?) from`)
+
+// Collected returns the chips each player collected from the pots of a
+// PokerStars hand history
+func Collected(raw string) map[string]float64 {
+	collected := map[string]float64{}
+	for _, line := range strings.Split(raw, "\n") {
+		if matches := collectedLine.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+			amount, _ := strconv.ParseFloat(matches[2], 64)
+			collected[matches[1]] += amount
+		}
+	}
+	return collected
+}
+
+// StakesBigBlind returns the big blind of a stakes string such as
+// "$0.50/$1", or 0 if it cannot be read
+func StakesBigBlind(stakes string) float64 {
+	parts := strings.Split(stakes, "/")
+	if len(parts) != 2 {
+		return 0
+	}
+	bb, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(parts[1]), "$"), 64)
+	if err != nil {
+		return 0
+	}
+	return bb
 }
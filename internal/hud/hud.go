@@ -0,0 +1,159 @@
+// Package hud builds live per-seat player statistics for the tables being
+// played and publishes them to overlay windows, through a callback (the
+// Wails event) and a localhost WebSocket.
+package hud
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"aniki/internal/database"
+)
+
+// activeTimeout is how long a table stays active without a new hand
+const activeTimeout = 15 * time.Minute
+
+// Seat is a player at a HUD table with their statistics
+type Seat struct {
+	Seat     int     `json:"seat"`
+	Name     string  `json:"name"`
+	Position string  `json:"position"`
+	Stack    float64 `json:"stack"`
+	Hero     bool    `json:"hero"`
+	database.PlayerStats
+}
+
+// Table is the HUD state of one table after its latest hand
+type Table struct {
+	Site      string    `json:"site"`
+	TableName string    `json:"table_name"`
+	HandID    string    `json:"hand_id"`
+	HeroName  string    `json:"hero_name"`
+	Seats     []Seat    `json:"seats"`
+	Updated   time.Time `json:"updated"`
+}
+
+// key identifies the table
+func (t Table) key() string {
+	return t.Site + "\x00" + t.TableName
+}
+
+// StatsSource provides the statistics of players on a site
+type StatsSource interface {
+	PlayerStats(siteID int, names []string) (map[string]database.PlayerStats, error)
+}
+
+// Service keeps the HUD state of the active tables and publishes every
+// change
+type Service struct {
+	mu      sync.Mutex
+	enabled bool
+	tables  map[string]Table
+	publish func(Table)
+	server  *server
+}
+
+// NewService creates an enabled HUD service calling publish, if not nil,
+// with every updated table
+func NewService(publish func(Table)) *Service {
+	return &Service{
+		enabled: true,
+		tables:  make(map[string]Table),
+		publish: publish,
+	}
+}
+
+// SetEnabled turns updates on or off. A disabled service forgets its
+// tables and ignores Update.
+func (s *Service) SetEnabled(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.enabled = enabled
+	if !enabled {
+		s.tables = make(map[string]Table)
+	}
+}
+
+// Update rebuilds the HUD of the tables of hands, the newest hand of each
+// table just imported from site, and publishes them
+func (s *Service) Update(stats StatsSource, site string, hands []database.Hand) error {
+	s.mu.Lock()
+	enabled := s.enabled
+	s.mu.Unlock()
+	if !enabled {
+		return nil
+	}
+
+	for _, hand := range hands {
+		names := make([]string, len(hand.Players))
+		for i, player := range hand.Players {
+			names[i] = player.Name
+		}
+		playerStats, err := stats.PlayerStats(hand.SiteID, names)
+		if err != nil {
+			return fmt.Errorf("failed to load HUD stats for %s: %w", hand.TableName, err)
+		}
+
+		table := Table{
+			Site:      site,
+			TableName: hand.TableName,
+			HandID:    hand.HandID,
+			HeroName:  hand.HeroName,
+			Seats:     make([]Seat, len(hand.Players)),
+			Updated:   time.Now(),
+		}
+		for i, player := range hand.Players {
+			table.Seats[i] = Seat{
+				Seat:        player.Seat,
+				Name:        player.Name,
+				Position:    player.Position,
+				Stack:       player.Stack,
+				Hero:        player.Name == hand.HeroName,
+				PlayerStats: playerStats[player.Name],
+			}
+		}
+		sort.Slice(table.Seats, func(i, j int) bool { return table.Seats[i].Seat < table.Seats[j].Seat })
+
+		s.mu.Lock()
+		s.tables[table.key()] = table
+		srv := s.server
+		s.mu.Unlock()
+
+		if s.publish != nil {
+			s.publish(table)
+		}
+		if srv != nil {
+			srv.broadcast(table)
+		}
+	}
+	return nil
+}
+
+// Tables returns the tables that had a hand within the active timeout,
+// most recently updated first
+func (s *Service) Tables() []Table {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tables := []Table{}
+	for key, table := range s.tables {
+		if time.Since(table.Updated) > activeTimeout {
+			delete(s.tables, key)
+			continue
+		}
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Updated.After(tables[j].Updated) })
+	return tables
+}
+
+// Reset forgets every table, e.g. after switching databases
+func (s *Service) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tables = make(map[string]Table)
+}
@@ -0,0 +1,209 @@
+package hud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeTimeout bounds how long a slow client may hold up a message
+	writeTimeout = 5 * time.Second
+	// clientBuffer is the number of tables queued per client before it is
+	// dropped as too slow
+	clientBuffer = 32
+)
+
+// server streams tables to WebSocket clients as JSON messages, one table
+// per message. New clients first receive every active table.
+type server struct {
+	http     *http.Server
+	listener net.Listener
+	mu       sync.Mutex
+	clients  map[*client]struct{}
+}
+
+// client is a connected WebSocket with its outgoing queue
+type client struct {
+	conn *websocket.Conn
+	send chan Table
+}
+
+// Listen serves the HUD WebSocket at ws://addr/hud, replacing any server
+// already running. addr should be a loopback address.
+func (s *Service) Listen(addr string) error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	srv := &server{listener: listener, clients: make(map[*client]struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hud", func(w http.ResponseWriter, r *http.Request) {
+		srv.serve(w, r, s.Tables())
+	})
+	srv.http = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		if err := srv.http.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HUD server stopped: %v", err)
+		}
+	}()
+
+	s.mu.Lock()
+	s.server = srv
+	s.mu.Unlock()
+
+	log.Printf("HUD WebSocket listening on ws://%s/hud", listener.Addr())
+	return nil
+}
+
+// Addr returns the address the WebSocket listens on, or "" when it is not
+// running
+func (s *Service) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.server == nil {
+		return ""
+	}
+	return s.server.listener.Addr().String()
+}
+
+// Close stops the WebSocket server and disconnects its clients
+func (s *Service) Close() error {
+	s.mu.Lock()
+	srv := s.server
+	s.server = nil
+	s.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+	err := srv.http.Shutdown(ctx)
+
+	// Hijacked WebSocket connections are not closed by Shutdown
+	srv.mu.Lock()
+	for c := range srv.clients {
+		c.conn.Close()
+	}
+	srv.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to stop HUD server: %w", err)
+	}
+	return nil
+}
+
+var upgrader = websocket.Upgrader{CheckOrigin: allowedOrigin}
+
+// allowedOrigin accepts clients without an origin, such as native
+// overlays, and pages served from the local machine, so that websites
+// open in a browser cannot read the HUD
+func allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "file", "wails":
+		return true
+	}
+	host := u.Hostname()
+	return host == "localhost" || host == "wails.localhost" || net.ParseIP(host).IsLoopback()
+}
+
+// serve upgrades a request to a WebSocket and streams tables to it until
+// it disconnects
+func (srv *server) serve(w http.ResponseWriter, r *http.Request, initial []Table) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has already replied with an error
+	}
+
+	c := &client{conn: conn, send: make(chan Table, clientBuffer)}
+	for _, table := range initial {
+		c.send <- table
+		if len(c.send) == cap(c.send) {
+			break
+		}
+	}
+
+	srv.mu.Lock()
+	srv.clients[c] = struct{}{}
+	srv.mu.Unlock()
+
+	go c.writeLoop()
+
+	// Clients only listen; reading detects when they go away
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	srv.remove(c)
+}
+
+// broadcast queues table for every client, dropping clients that are too
+// slow to keep up
+func (srv *server) broadcast(table Table) {
+	srv.mu.Lock()
+	var slow []*client
+	for c := range srv.clients {
+		select {
+		case c.send <- table:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	srv.mu.Unlock()
+
+	for _, c := range slow {
+		srv.remove(c)
+	}
+}
+
+// remove disconnects c
+func (srv *server) remove(c *client) {
+	srv.mu.Lock()
+	if _, ok := srv.clients[c]; ok {
+		delete(srv.clients, c)
+		close(c.send)
+	}
+	srv.mu.Unlock()
+	c.conn.Close()
+}
+
+// writeLoop sends queued tables until the queue is closed
+func (c *client) writeLoop() {
+	for table := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := c.conn.WriteJSON(table); err != nil {
+			c.conn.Close()
+			for range c.send {
+				// Drain until removed
+			}
+			return
+		}
+	}
+}
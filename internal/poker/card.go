@@ -0,0 +1,151 @@
+// Package poker contains card representations and poker hand logic shared
+// by the parser, the importer and the repositories.
+package poker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// rankChars and suitChars list ranks and suits in encoding order
+const (
+	rankChars = "23456789TJQKA"
+	suitChars = "cdhs"
+)
+
+// Card is a playing card encoded as rank*4 + suit, with ranks from 0 (deuce)
+// to 12 (ace) and suits in the order clubs, diamonds, hearts, spades
+type Card uint8
+
+// NewCard returns the card of rank (0-12) and suit (0-3)
+func NewCard(rank, suit int) Card {
+	return Card(rank*4 + suit)
+}
+
+// ParseCard parses a card such as "Ah", "Td" or "10c"
+func ParseCard(s string) (Card, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "10") {
+		s = "T" + s[2:]
+	}
+	if len(s) != 2 {
+		return 0, fmt.Errorf("invalid card %q", s)
+	}
+	rank := strings.IndexByte(rankChars, upper(s[0]))
+	suit := strings.IndexByte(suitChars, lower(s[1]))
+	if rank < 0 || suit < 0 {
+		return 0, fmt.Errorf("invalid card %q", s)
+	}
+	return NewCard(rank, suit), nil
+}
+
+// ParseCards parses a list of cards
+func ParseCards(cards []string) ([]Card, error) {
+	out := make([]Card, 0, len(cards))
+	for _, s := range cards {
+		c, err := ParseCard(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Rank returns the rank from 0 (deuce) to 12 (ace)
+func (c Card) Rank() int {
+	return int(c) / 4
+}
+
+// Suit returns the suit from 0 (clubs) to 3 (spades)
+func (c Card) Suit() int {
+	return int(c) % 4
+}
+
+// String returns the card in hand history notation, e.g. "Ah"
+func (c Card) String() string {
+	return string([]byte{rankChars[c.Rank()], suitChars[c.Suit()]})
+}
+
+// RankChar returns the character of a rank, e.g. 'A' for 12
+func RankChar(rank int) byte {
+	return rankChars[rank]
+}
+
+// SuitChar returns the character of a suit, e.g. 'h' for 2
+func SuitChar(suit int) byte {
+	return suitChars[suit]
+}
+
+// HandClass returns the starting hand class of two hole cards, such as
+// "AKs", "72o" or "QQ", with the higher rank first. It returns "" for any
+// other number of cards.
+func HandClass(cards []Card) string {
+	if len(cards) != 2 {
+		return ""
+	}
+	high, low := cards[0], cards[1]
+	if low.Rank() > high.Rank() {
+		high, low = low, high
+	}
+	class := []byte{RankChar(high.Rank()), RankChar(low.Rank())}
+	switch {
+	case high.Rank() == low.Rank():
+	case high.Suit() == low.Suit():
+		class = append(class, 's')
+	default:
+		class = append(class, 'o')
+	}
+	return string(class)
+}
+
+// Texture describes a flop
+type Texture struct {
+	Suits     int  // distinct suits: 1 monotone, 2 two-tone, 3 rainbow
+	Paired    bool // two or three cards share a rank
+	Connected bool // a straight is possible using two hole cards
+	HighRank  int  // highest rank, 0 (deuce) to 12 (ace)
+}
+
+// FlopTexture describes the first three cards of board. ok is false when
+// the board has no flop.
+func FlopTexture(board []Card) (texture Texture, ok bool) {
+	if len(board) < 3 {
+		return Texture{}, false
+	}
+	flop := board[:3]
+
+	suits := map[int]bool{}
+	ranks := make([]int, 0, 3)
+	for _, c := range flop {
+		suits[c.Suit()] = true
+		ranks = append(ranks, c.Rank())
+	}
+	sort.Ints(ranks)
+
+	texture.Suits = len(suits)
+	texture.Paired = ranks[0] == ranks[1] || ranks[1] == ranks[2]
+	texture.HighRank = ranks[2]
+	if !texture.Paired {
+		// Three distinct ranks within a five rank window, counting the
+		// ace low for the wheel
+		texture.Connected = ranks[2]-ranks[0] <= 4 ||
+			(ranks[2] == 12 && ranks[1] <= 3)
+	}
+	return texture, true
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c - 'A' + 'a'
+	}
+	return c
+}
@@ -0,0 +1,230 @@
+package poker
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+const (
+	// maxOmahaRunouts bounds the Omaha boards enumerated exactly. An Omaha
+	// all-in before the flop has about a million completions, which take
+	// seconds to evaluate.
+	maxOmahaRunouts = 50000
+	// omahaSamples is the number of random boards dealt instead
+	omahaSamples = 20000
+)
+
+// Equity returns each hand's expected share of the pot over every possible
+// completion of board from the remaining deck. Ties split the pot. In hi/lo
+// games each half is shared separately, and the high hands take the whole
+// pot when no low qualifies. Omaha boards with more than maxOmahaRunouts
+// completions are estimated from a fixed sequence of random run-outs, so
+// the same hands always get the same equity.
+func Equity(gameType string, hands [][]Card, board []Card) ([]float64, error) {
+	if len(hands) < 2 {
+		return nil, fmt.Errorf("equity needs at least two hands, got %d", len(hands))
+	}
+	if len(board) > 5 {
+		return nil, fmt.Errorf("board has %d cards", len(board))
+	}
+
+	omaha := IsOmaha(gameType)
+	hiLo := IsHiLo(gameType)
+
+	var used uint64
+	take := func(c Card) error {
+		if c >= 52 {
+			return fmt.Errorf("invalid card %d", c)
+		}
+		if used&(1<<c) != 0 {
+			return fmt.Errorf("card %s is dealt twice", c)
+		}
+		used |= 1 << c
+		return nil
+	}
+	for _, hand := range hands {
+		if omaha && len(hand) < 4 || !omaha && len(hand) != 2 {
+			return nil, fmt.Errorf("hand %v has the wrong number of cards for %s", hand, gameType)
+		}
+		for _, c := range hand {
+			if err := take(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, c := range board {
+		if err := take(c); err != nil {
+			return nil, err
+		}
+	}
+
+	var deck []Card
+	for c := Card(0); c < 52; c++ {
+		if used&(1<<c) == 0 {
+			deck = append(deck, c)
+		}
+	}
+
+	e := equity{
+		hands:  hands,
+		omaha:  omaha,
+		hiLo:   hiLo,
+		shares: make([]float64, len(hands)),
+		values: make([]HandValue, len(hands)),
+		lows:   make([]LowValue, len(hands)),
+		hasLow: make([]bool, len(hands)),
+	}
+	if omaha {
+		e.holePairs = make([][][4]uint16, len(hands))
+		for i, hand := range hands {
+			e.holePairs[i] = cardPairs(nil, hand)
+		}
+	} else {
+		e.holeSuits = make([][4]uint16, len(hands))
+		for i, hand := range hands {
+			for _, c := range hand {
+				e.holeSuits[i][c.Suit()] |= 1 << c.Rank()
+			}
+		}
+	}
+
+	var full [5]Card
+	copy(full[:], board)
+	if omaha && combinations(len(deck), 5-len(board)) > maxOmahaRunouts {
+		e.sample(deck, full, len(board), omahaSamples)
+	} else {
+		e.enumerate(deck, full, len(board), 0)
+	}
+
+	for i := range e.shares {
+		e.shares[i] /= e.runouts
+	}
+	return e.shares, nil
+}
+
+// equity holds the state of an equity enumeration
+type equity struct {
+	hands     [][]Card
+	holeSuits [][4]uint16   // Hold'em hole cards as rank masks per suit
+	holePairs [][][4]uint16 // Omaha hole card pairs as rank masks per suit
+	omaha     bool
+	hiLo      bool
+
+	shares  []float64
+	runouts float64
+
+	// Per run-out scratch space
+	triples [maxTriples][4]uint16
+	values  []HandValue
+	lows    []LowValue
+	hasLow  []bool
+}
+
+// enumerate deals every remaining combination of cards from deck[from:]
+// into board[n:] and scores each complete board
+func (e *equity) enumerate(deck []Card, board [5]Card, n, from int) {
+	if n == 5 {
+		e.score(board)
+		return
+	}
+	for i := from; i <= len(deck)-(5-n); i++ {
+		board[n] = deck[i]
+		e.enumerate(deck, board, n+1, i+1)
+	}
+}
+
+// sample deals n random completions of board[from:] from deck and scores
+// each of them
+func (e *equity) sample(deck []Card, board [5]Card, from, n int) {
+	deck = append([]Card(nil), deck...)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for ; n > 0; n-- {
+		// Partial shuffle of the cards needed to the front of the deck
+		for i := 0; i < 5-from; i++ {
+			j := i + rng.IntN(len(deck)-i)
+			deck[i], deck[j] = deck[j], deck[i]
+			board[from+i] = deck[i]
+		}
+		e.score(board)
+	}
+}
+
+// combinations returns n choose k
+func combinations(n, k int) int {
+	c := 1
+	for i := 0; i < k; i++ {
+		c = c * (n - i) / (i + 1)
+	}
+	return c
+}
+
+// score adds the pot shares of one complete board
+func (e *equity) score(board [5]Card) {
+	e.runouts++
+
+	var boardSuits [4]uint16
+	var triples [][4]uint16
+	if e.omaha {
+		triples = cardTriples(e.triples[:0], board[:])
+	} else {
+		for _, c := range board {
+			boardSuits[c.Suit()] |= 1 << c.Rank()
+		}
+	}
+
+	var best HandValue
+	anyLow := false
+	var bestLow LowValue
+	for i, hand := range e.hands {
+		if e.omaha {
+			e.values[i] = bestOmaha(e.holePairs[i], triples)
+		} else {
+			h := e.holeSuits[i]
+			e.values[i] = evaluateSuits([4]uint16{
+				h[0] | boardSuits[0], h[1] | boardSuits[1], h[2] | boardSuits[2], h[3] | boardSuits[3],
+			})
+		}
+		if e.values[i] > best {
+			best = e.values[i]
+		}
+
+		if e.hiLo {
+			if e.omaha {
+				e.lows[i], e.hasLow[i] = EvaluateOmahaLow(hand, board[:])
+			} else {
+				e.lows[i], e.hasLow[i] = EvaluateLow(append(append(make([]Card, 0, 7), hand...), board[:]...)...)
+			}
+			if e.hasLow[i] && (!anyLow || e.lows[i] < bestLow) {
+				bestLow, anyLow = e.lows[i], true
+			}
+		}
+	}
+
+	highPot := 1.0
+	if anyLow {
+		highPot = 0.5
+		winners := 0
+		for i := range e.hands {
+			if e.hasLow[i] && e.lows[i] == bestLow {
+				winners++
+			}
+		}
+		for i := range e.hands {
+			if e.hasLow[i] && e.lows[i] == bestLow {
+				e.shares[i] += 0.5 / float64(winners)
+			}
+		}
+	}
+
+	winners := 0
+	for _, v := range e.values {
+		if v == best {
+			winners++
+		}
+	}
+	for i, v := range e.values {
+		if v == best {
+			e.shares[i] += highPot / float64(winners)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package poker
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEquityOmahaPreflopSampled(t *testing.T) {
+	hands := [][]Card{parse(t, "Ah As Kd Qd"), parse(t, "7c 8c 9h Th")}
+	first, err := Equity("Omaha Pot Limit", hands, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := first[0] + first[1]; math.Abs(sum-1) > 1e-9 {
+		t.Errorf("shares sum to %v", sum)
+	}
+	if first[0] < 0.5 || first[0] > 0.75 {
+		t.Errorf("aces have %v equity", first[0])
+	}
+
+	// The sample is fixed, so reimporting a hand gives the same equity
+	second, _ := Equity("Omaha Pot Limit", hands, nil)
+	if first[0] != second[0] {
+		t.Errorf("equity changed from %v to %v", first[0], second[0])
+	}
+}
+
+func BenchmarkEquityOmahaPreflop(b *testing.B) {
+	hands := [][]Card{parse(b, "Ah As Kd Qd"), parse(b, "7c 8c 9h Th")}
+	for i := 0; i < b.N; i++ {
+		Equity("Omaha Pot Limit", hands, nil)
+	}
+}
@@ -0,0 +1,275 @@
+package poker
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Category is the class of a made high hand
+type Category int
+
+// Hand categories, weakest first
+const (
+	HighCard Category = iota
+	OnePair
+	TwoPair
+	ThreeOfAKind
+	Straight
+	Flush
+	FullHouse
+	FourOfAKind
+	StraightFlush
+)
+
+var categoryNames = [...]string{
+	"High Card",
+	"One Pair",
+	"Two Pair",
+	"Three of a Kind",
+	"Straight",
+	"Flush",
+	"Full House",
+	"Four of a Kind",
+	"Straight Flush",
+}
+
+// String returns the category name, e.g. "Full House"
+func (c Category) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return fmt.Sprintf("Category(%d)", int(c))
+	}
+	return categoryNames[c]
+}
+
+// HandValue is the strength of a high hand. Greater values are stronger
+// and equal values tie. The category is stored in bits 20 and up, followed
+// by up to five ranks of four bits each in order of significance.
+type HandValue uint32
+
+// Category returns the category of the hand
+func (v HandValue) Category() Category {
+	return Category(v >> 20)
+}
+
+// ranks returns the five significance-ordered ranks of the hand
+func (v HandValue) ranks() [5]int {
+	var r [5]int
+	for i := range r {
+		r[i] = int(v>>(16-4*i)) & 0xF
+	}
+	return r
+}
+
+// String describes the hand, e.g. "Full House, Kings full of Sevens"
+func (v HandValue) String() string {
+	r := v.ranks()
+	switch v.Category() {
+	case HighCard:
+		return fmt.Sprintf("High Card, %s", rankName(r[0]))
+	case OnePair:
+		return fmt.Sprintf("One Pair, %s", rankPlural(r[0]))
+	case TwoPair:
+		return fmt.Sprintf("Two Pair, %s and %s", rankPlural(r[0]), rankPlural(r[1]))
+	case ThreeOfAKind:
+		return fmt.Sprintf("Three of a Kind, %s", rankPlural(r[0]))
+	case Straight:
+		return fmt.Sprintf("Straight, %s high", rankName(r[0]))
+	case Flush:
+		return fmt.Sprintf("Flush, %s high", rankName(r[0]))
+	case FullHouse:
+		return fmt.Sprintf("Full House, %s full of %s", rankPlural(r[0]), rankPlural(r[1]))
+	case FourOfAKind:
+		return fmt.Sprintf("Four of a Kind, %s", rankPlural(r[0]))
+	case StraightFlush:
+		if r[0] == 12 {
+			return "Royal Flush"
+		}
+		return fmt.Sprintf("Straight Flush, %s high", rankName(r[0]))
+	}
+	return v.Category().String()
+}
+
+var rankNames = [...]string{"Two", "Three", "Four", "Five", "Six", "Seven", "Eight", "Nine", "Ten", "Jack", "Queen", "King", "Ace"}
+
+func rankName(rank int) string { return rankNames[rank] }
+
+func rankPlural(rank int) string {
+	if rank == 4 {
+		return "Sixes"
+	}
+	return rankNames[rank] + "s"
+}
+
+// makeValue packs a category and up to five ranks into a HandValue
+func makeValue(c Category, ranks ...int) HandValue {
+	v := uint32(c) << 20
+	for i, r := range ranks {
+		v |= uint32(r) << (16 - 4*i)
+	}
+	return HandValue(v)
+}
+
+// Evaluate returns the value of the best five card high hand among cards,
+// typically the five to seven cards of a Hold'em hand. With fewer than
+// five cards only pairs, trips and quads are made.
+func Evaluate(cards ...Card) HandValue {
+	var suits [4]uint16
+	for _, c := range cards {
+		suits[c.Suit()] |= 1 << c.Rank()
+	}
+	return evaluateSuits(suits)
+}
+
+// evaluateSuits evaluates a set of distinct cards given as one rank mask
+// per suit. Rank counts are derived with bit operations so no loop over
+// ranks is needed.
+func evaluateSuits(s [4]uint16) HandValue {
+	all := s[0] | s[1] | s[2] | s[3]
+
+	// Flushes and straight flushes
+	var flush uint16
+	for _, mask := range s {
+		if bits.OnesCount16(mask) >= 5 {
+			if top := straightTop(mask); top >= 0 {
+				return makeValue(StraightFlush, top)
+			}
+			flush = mask
+		}
+	}
+
+	quads := s[0] & s[1] & s[2] & s[3]
+	if quads != 0 {
+		q := topRank(quads)
+		return withKickers(makeValue(FourOfAKind, q), 1, all&^(1<<q), 1)
+	}
+
+	threePlus := s[0]&s[1]&s[2] | s[0]&s[1]&s[3] | s[0]&s[2]&s[3] | s[1]&s[2]&s[3]
+	twoPlus := s[0]&s[1] | s[0]&s[2] | s[0]&s[3] | s[1]&s[2] | s[1]&s[3] | s[2]&s[3]
+	pairs := twoPlus &^ threePlus
+
+	if threePlus != 0 {
+		t := topRank(threePlus)
+		if rest := threePlus&^(1<<t) | pairs; rest != 0 {
+			return makeValue(FullHouse, t, topRank(rest))
+		}
+	}
+	if flush != 0 {
+		return withKickers(makeValue(Flush), 0, flush, 5)
+	}
+	if top := straightTop(all); top >= 0 {
+		return makeValue(Straight, top)
+	}
+	if threePlus != 0 {
+		t := topRank(threePlus)
+		return withKickers(makeValue(ThreeOfAKind, t), 1, all&^(1<<t), 2)
+	}
+	if pairs != 0 {
+		high := topRank(pairs)
+		if rest := pairs &^ (1 << high); rest != 0 {
+			low := topRank(rest)
+			return withKickers(makeValue(TwoPair, high, low), 2, all&^(1<<high|1<<low), 1)
+		}
+		return withKickers(makeValue(OnePair, high), 1, all&^(1<<high), 3)
+	}
+	return withKickers(makeValue(HighCard), 0, all, 5)
+}
+
+// rankTable holds, for every rank mask, its ranks from the highest down
+// packed as HandValue rank positions, e.g. the kickers of a hand
+var rankTable = func() (table [1 << 13]uint32) {
+	for mask := range table {
+		table[mask] = uint32(withKickers(0, 0, uint16(mask), 5))
+	}
+	return table
+}()
+
+// unpairedTable holds the value of every five distinct ranks, without
+// regard to suits: a straight or a high card hand
+var unpairedTable = func() (table [1 << 13]HandValue) {
+	for mask := range table {
+		if bits.OnesCount16(uint16(mask)) != 5 {
+			continue
+		}
+		if top := straightTop(uint16(mask)); top >= 0 {
+			table[mask] = makeValue(Straight, top)
+		} else {
+			table[mask] = kickers(makeValue(HighCard), 0, uint16(mask))
+		}
+	}
+	return table
+}()
+
+// kickers adds the ranks of mask to v from rank position pos. mask must
+// hold exactly the ranks wanted.
+func kickers(v HandValue, pos int, mask uint16) HandValue {
+	return v | HandValue(rankTable[mask]>>(4*pos))
+}
+
+// evaluateFive is evaluateSuits for exactly five distinct cards, as Omaha
+// hands are evaluated. The number of distinct ranks tells most categories
+// apart without the general checks.
+func evaluateFive(s [4]uint16) HandValue {
+	all := s[0] | s[1] | s[2] | s[3]
+	ranks := bits.OnesCount16(all)
+
+	if ranks == 5 {
+		v := unpairedTable[all]
+		if s[0] == all || s[1] == all || s[2] == all || s[3] == all {
+			// Same ranks, higher category
+			if v.Category() == Straight {
+				return v + makeValue(StraightFlush-Straight)
+			}
+			return v + makeValue(Flush-HighCard)
+		}
+		return v
+	}
+
+	// Ranks held twice (or four times) cancel out of the exclusive or
+	even := all &^ (s[0] ^ s[1] ^ s[2] ^ s[3])
+	switch ranks {
+	case 4:
+		return kickers(makeValue(OnePair, topRank(even)), 1, all&^even)
+	case 3:
+		if even != 0 {
+			return kickers(kickers(makeValue(TwoPair), 0, even), 2, all&^even)
+		}
+		t := topRank(s[0]&s[1]&s[2] | s[0]&s[1]&s[3] | s[0]&s[2]&s[3] | s[1]&s[2]&s[3])
+		return kickers(makeValue(ThreeOfAKind, t), 1, all&^(1<<t))
+	}
+
+	// Two ranks: four of a kind or a full house
+	if quads := s[0] & s[1] & s[2] & s[3]; quads != 0 {
+		return kickers(makeValue(FourOfAKind, topRank(quads)), 1, all&^quads)
+	}
+	return makeValue(FullHouse, topRank(all&^even), topRank(even))
+}
+
+// topRank returns the highest rank set in a non-empty rank mask
+func topRank(mask uint16) int {
+	return 15 - bits.LeadingZeros16(mask)
+}
+
+// straightTop returns the top rank of the highest straight in a rank mask,
+// or -1. The wheel (A-2-3-4-5) has top rank 3.
+func straightTop(mask uint16) int {
+	// Shift ranks up one place and copy the ace below the deuce, then keep
+	// the bits that start a run of five
+	m := mask<<1 | mask>>12&1
+	runs := m & (m >> 1) & (m >> 2) & (m >> 3) & (m >> 4)
+	if runs == 0 {
+		return -1
+	}
+	return topRank(runs) + 3
+}
+
+// withKickers adds the n highest ranks in mask to v, starting at rank
+// position pos
+func withKickers(v HandValue, pos int, mask uint16, n int) HandValue {
+	for ; n > 0 && mask != 0 && pos < 5; n-- {
+		r := topRank(mask)
+		v |= HandValue(r) << (16 - 4*pos)
+		mask &^= 1 << r
+		pos++
+	}
+	return v
+}
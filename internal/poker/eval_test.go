@@ -0,0 +1,233 @@
+package poker
+
+import (
+	"strings"
+	"testing"
+)
+
+// parse parses space separated cards such as "Ah Kd 7c"
+func parse(t testing.TB, cards string) []Card {
+	t.Helper()
+	parsed, err := ParseCards(strings.Fields(cards))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestEvaluateCategories(t *testing.T) {
+	// Weakest first, so every hand must beat the one before it
+	tests := []struct {
+		cards    string
+		category Category
+		name     string
+	}{
+		{"Ah Qd 9c 7s 4h 3d 2c", HighCard, "High Card, Ace"},
+		{"Ah Kd 9c 7s 4h 3d 2c", HighCard, "High Card, Ace"},
+		{"2h 2d 9c 7s 4h 3d Kc", OnePair, "One Pair, Twos"},
+		{"Ah Ad 9c 7s 4h 3d 2c", OnePair, "One Pair, Aces"},
+		{"Ah Ad Kc 7s 4h 3d 2c", OnePair, "One Pair, Aces"},
+		{"Ah Ad 9c 9s 4h 3d 2c", TwoPair, "Two Pair, Aces and Nines"},
+		{"Ah Ad Kc Ks 4h 3d 2c", TwoPair, "Two Pair, Aces and Kings"},
+		{"6h 6d 6c Ks 4h 3d 2c", ThreeOfAKind, "Three of a Kind, Sixes"},
+		{"Ah 2d 3c 4s 5h 9d Kc", Straight, "Straight, Five high"},
+		{"6h 2d 3c 4s 5h 9d Kc", Straight, "Straight, Six high"},
+		{"Ah Kd Qc Js Th 3d 2c", Straight, "Straight, Ace high"},
+		{"Ah Jh 9h 7h 4h 3d 2c", Flush, "Flush, Ace high"},
+		{"Ah Kh 9h 7h 4h 3d 2c", Flush, "Flush, Ace high"},
+		{"3h 3d 3c 2s 2h 9d Kc", FullHouse, "Full House, Threes full of Twos"},
+		{"Kh Kd Kc 7s 7h 7d 2c", FullHouse, "Full House, Kings full of Sevens"},
+		{"9h 9d 9c 9s 4h 3d 2c", FourOfAKind, "Four of a Kind, Nines"},
+		{"Ah 2h 3h 4h 5h 9d Kc", StraightFlush, "Straight Flush, Five high"},
+		{"Ah Kh Qh Jh Th 9h 2c", StraightFlush, "Royal Flush"},
+	}
+
+	var previous HandValue
+	for i, tt := range tests {
+		v := Evaluate(parse(t, tt.cards)...)
+		if v.Category() != tt.category {
+			t.Errorf("%s: category %v, want %v", tt.cards, v.Category(), tt.category)
+		}
+		if v.String() != tt.name {
+			t.Errorf("%s: %q, want %q", tt.cards, v.String(), tt.name)
+		}
+		if i > 0 && v <= previous {
+			t.Errorf("%s does not beat %s", tt.cards, tests[i-1].cards)
+		}
+		previous = v
+	}
+}
+
+func TestEvaluateTies(t *testing.T) {
+	tests := []struct{ a, b string }{
+		// Only the best five cards count
+		{"Ah Ad Kc Qs Jh 3d 2c", "As Ac Kd Qh Jc 4s 2d"},
+		// Suits never break ties
+		{"Ah 2d 3c 4s 5h 9d Kc", "As 2c 3d 4h 5s 9c Kd"},
+		// A board straight plays for everyone
+		{"6h 7d 8c 9s Th 2d 2c", "6h 7d 8c 9s Th Ac Kd"},
+	}
+	for _, tt := range tests {
+		a, b := Evaluate(parse(t, tt.a)...), Evaluate(parse(t, tt.b)...)
+		if a != b {
+			t.Errorf("%s (%v) and %s (%v) should tie", tt.a, a, tt.b, b)
+		}
+	}
+}
+
+func TestEvaluateWheel(t *testing.T) {
+	tests := []struct {
+		cards    string
+		category Category
+	}{
+		{"Ah 2d 3c 4s 5h", Straight},
+		{"5h 4d 3c 2s Ah Ad Ac", Straight},
+		{"Ah 2d 3c 4s 6h", HighCard},
+		{"Kh Ad 2c 3s 4h", HighCard}, // no wrapping round the ace
+		{"Ac 2c 3c 4c 5d 6c", Flush},
+		{"Ac 2c 3c 4c 5c 6c", StraightFlush},
+	}
+	for _, tt := range tests {
+		if got := Evaluate(parse(t, tt.cards)...).Category(); got != tt.category {
+			t.Errorf("%s: %v, want %v", tt.cards, got, tt.category)
+		}
+	}
+
+	wheel := Evaluate(parse(t, "Ah 2d 3c 4s 5h")...)
+	sixHigh := Evaluate(parse(t, "2d 3c 4s 5h 6h")...)
+	if wheel >= sixHigh {
+		t.Errorf("wheel %v should lose to %v", wheel, sixHigh)
+	}
+}
+
+func TestEvaluateOmaha(t *testing.T) {
+	tests := []struct {
+		hole, board string
+		want        string
+	}{
+		// One heart in hand cannot use the four flush on board
+		{"Ah Kd Qc Jc", "Th 9h 8h 2h 3s", "Straight, Queen high"},
+		// Two hearts in hand make the flush
+		{"Ah Kh Qc Jc", "Th 9h 8h 2d 3s", "Flush, Ace high"},
+		// Four of a kind on board plays as trips with two hole cards
+		{"As Ks Qs Js", "9s 9h 9d 9c 2s", "Three of a Kind, Nines"},
+		// Quads in hand only use two of the four
+		{"7s 7h 7d 7c", "Ks Qh 2d 3c 4s", "One Pair, Sevens"},
+		// Three hearts in hand and two on board make neither the flush nor
+		// broadway, only the wheel
+		{"Ah Kh Qh 2c", "Jh Th 3d 4c 5s", "Straight, Five high"},
+	}
+	for _, tt := range tests {
+		got := EvaluateOmaha(parse(t, tt.hole), parse(t, tt.board)).String()
+		if got != tt.want {
+			t.Errorf("%s on %s: %q, want %q", tt.hole, tt.board, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateLow(t *testing.T) {
+	tests := []struct {
+		cards string
+		want  string // "" when no low qualifies
+	}{
+		{"Ah 2d 3c 4s 5h", "5-4-3-2-A"},
+		{"Ah 2d 4c 6s 8h", "8-6-4-2-A"},
+		{"Ah 2d 3c 4s 5h 6d 7c", "5-4-3-2-A"},
+		{"Ah Ad 2c 2s 3h 4d 7c", "7-4-3-2-A"},
+		{"Ah 2d 3c 4s 9h", ""},
+		{"Ah Ad 2c 3s 4h Kd Kc", ""},
+		{"6h 5d 4c 3s 2h", "6-5-4-3-2"},
+	}
+	for _, tt := range tests {
+		v, ok := EvaluateLow(parse(t, tt.cards)...)
+		got := ""
+		if ok {
+			got = v.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.cards, got, tt.want)
+		}
+	}
+
+	// Lower is better, comparing the highest card first
+	order := []string{"Ah 2d 3c 4s 5h", "Ah 2d 3c 4s 6h", "Ah 2d 3c 5s 6h", "2h 3d 4c 5s 6h", "Ah 2d 3c 4s 8h"}
+	var previous LowValue
+	for i, cards := range order {
+		v, _ := EvaluateLow(parse(t, cards)...)
+		if i > 0 && v <= previous {
+			t.Errorf("%s should be a worse low than %s", cards, order[i-1])
+		}
+		previous = v
+	}
+}
+
+func TestEvaluateOmahaLow(t *testing.T) {
+	tests := []struct {
+		hole, board string
+		want        string
+	}{
+		{"Ah 2d Kc Ks", "3h 4d 5c Qs Jh", "5-4-3-2-A"},
+		// All three board cards must play
+		{"Ah 2d 3c 4s", "6h 7d 8c Ks Kh", "8-7-6-2-A"},
+		// Only one low card in hand
+		{"Ah Kd Kc Qs", "2h 3d 4c 5s 6h", ""},
+		// Only two low cards on board
+		{"Ah 2d 3c 4s", "5h 6d Kc Qs Jh", ""},
+		// Paired low cards in hand do not count twice
+		{"Ah Ad Kc Qs", "2h 3d 4c Ks Qh", ""},
+	}
+	for _, tt := range tests {
+		v, ok := EvaluateOmahaLow(parse(t, tt.hole), parse(t, tt.board))
+		got := ""
+		if ok {
+			got = v.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s on %s: %q, want %q", tt.hole, tt.board, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateFiveMatchesEvaluate(t *testing.T) {
+	// Every five card hand
+	var hand [5]Card
+	var deal func(n int, from Card)
+	deal = func(n int, from Card) {
+		if n == 5 {
+			var s [4]uint16
+			for _, c := range hand {
+				s[c.Suit()] |= 1 << c.Rank()
+			}
+			if got, want := evaluateFive(s), evaluateSuits(s); got != want {
+				t.Fatalf("%v: %v, want %v", hand, got, want)
+			}
+			return
+		}
+		for c := from; c < 52; c++ {
+			hand[n] = c
+			deal(n+1, c+1)
+		}
+	}
+	deal(0, 0)
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	cards := parse(b, "Ah Kh Qd Jc 9s 9h 2c")
+	for i := 0; i < b.N; i++ {
+		Evaluate(cards...)
+	}
+}
+
+func BenchmarkEvaluateOmaha(b *testing.B) {
+	hole, board := parse(b, "Ah Kh Qd Jc"), parse(b, "Th 9h 8c 2s 3h")
+	for i := 0; i < b.N; i++ {
+		EvaluateOmaha(hole, board)
+	}
+}
+
+func BenchmarkEvaluateOmahaLow(b *testing.B) {
+	hole, board := parse(b, "Ah 2h Qd Jc"), parse(b, "3h 4d 8c Ks 5h")
+	for i := 0; i < b.N; i++ {
+		EvaluateOmahaLow(hole, board)
+	}
+}
@@ -0,0 +1,48 @@
+package poker
+
+import "strings"
+
+// IsOmaha reports whether a game type such as "Omaha Pot Limit" is played
+// with the exactly-two-from-hand rule
+func IsOmaha(gameType string) bool {
+	return strings.Contains(strings.ToLower(gameType), "omaha")
+}
+
+// IsHiLo reports whether a game type splits the pot with an eight-or-better
+// low, e.g. "Omaha Hi/Lo Pot Limit"
+func IsHiLo(gameType string) bool {
+	game := strings.ToLower(gameType)
+	return strings.Contains(game, "hi/lo") || strings.Contains(game, "8-or-better") || strings.Contains(game, "eight-or-better")
+}
+
+// MadeHand labels the hand a player made with hole at the end of a hand of
+// gameType, e.g. "Flush" or "Two Pair; 8-6-4-2-A low" in hi/lo games. It
+// returns "" until there are enough cards to make a five card hand.
+func MadeHand(gameType string, hole, board []Card) string {
+	var label string
+	omaha := IsOmaha(gameType)
+
+	switch {
+	case omaha && len(hole) >= 2 && len(board) >= 3:
+		label = EvaluateOmaha(hole, board).Category().String()
+	case !omaha && len(hole)+len(board) >= 5:
+		cards := append(append(make([]Card, 0, 7), hole...), board...)
+		label = Evaluate(cards...).Category().String()
+	default:
+		return ""
+	}
+
+	if IsHiLo(gameType) {
+		var low LowValue
+		var ok bool
+		if omaha {
+			low, ok = EvaluateOmahaLow(hole, board)
+		} else {
+			low, ok = EvaluateLow(append(append([]Card{}, hole...), board...)...)
+		}
+		if ok {
+			label += "; " + low.String() + " low"
+		}
+	}
+	return label
+}
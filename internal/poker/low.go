@@ -0,0 +1,63 @@
+package poker
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// LowValue is the strength of an ace-to-five eight-or-better low hand.
+// Smaller values are stronger and equal values tie. Bit r is set for each
+// of the five ranks of the hand, counting the ace as 0 and the eight as 7,
+// so comparing values compares the highest card first.
+type LowValue uint16
+
+// lowRank maps a card rank to its ace-to-five rank, -1 above eight
+func lowRank(rank int) int {
+	switch {
+	case rank == 12:
+		return 0
+	case rank <= 6:
+		return rank + 1
+	default:
+		return -1
+	}
+}
+
+// EvaluateLow returns the best eight-or-better low among cards. ok is false
+// when the cards do not hold five distinct ranks of eight or lower.
+func EvaluateLow(cards ...Card) (value LowValue, ok bool) {
+	var mask uint16
+	for _, c := range cards {
+		if r := lowRank(c.Rank()); r >= 0 {
+			mask |= 1 << r
+		}
+	}
+	return bestLow(mask)
+}
+
+// bestLow keeps the five lowest ranks of an ace-to-five rank mask
+func bestLow(mask uint16) (LowValue, bool) {
+	if bits.OnesCount16(mask) < 5 {
+		return 0, false
+	}
+	for bits.OnesCount16(mask) > 5 {
+		mask &^= 1 << (15 - bits.LeadingZeros16(mask))
+	}
+	return LowValue(mask), true
+}
+
+// String returns the low from the highest card down, e.g. "8-6-4-2-A"
+func (v LowValue) String() string {
+	var parts []string
+	for r := 7; r >= 0; r-- {
+		if v&(1<<r) == 0 {
+			continue
+		}
+		if r == 0 {
+			parts = append(parts, "A")
+		} else {
+			parts = append(parts, string(RankChar(r-1)))
+		}
+	}
+	return strings.Join(parts, "-")
+}
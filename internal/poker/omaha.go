@@ -0,0 +1,108 @@
+package poker
+
+// Combinations of up to six hole cards and five board cards, which
+// EvaluateOmaha keeps on the stack
+const (
+	maxPairs   = 15
+	maxTriples = 10
+)
+
+// EvaluateOmaha returns the best high hand made from exactly two of the
+// hole cards and exactly three of the board cards
+func EvaluateOmaha(hole, board []Card) HandValue {
+	var pairs [maxPairs][4]uint16
+	var triples [maxTriples][4]uint16
+	return bestOmaha(cardPairs(pairs[:0], hole), cardTriples(triples[:0], board))
+}
+
+// bestOmaha returns the best hand combining one of the hole card pairs with
+// one of the board triples, each given as rank masks per suit
+func bestOmaha(pairs, triples [][4]uint16) HandValue {
+	var best HandValue
+	for _, p := range pairs {
+		for _, t := range triples {
+			v := evaluateFive(orMasks(p, t))
+			if v > best {
+				best = v
+			}
+		}
+	}
+	return best
+}
+
+// cardMasks holds each card as a rank mask per suit
+var cardMasks = func() (masks [52][4]uint16) {
+	for c := range masks {
+		masks[c][Card(c).Suit()] = 1 << Card(c).Rank()
+	}
+	return masks
+}()
+
+// orMasks combines two sets of cards given as rank masks per suit
+func orMasks(a, b [4]uint16) [4]uint16 {
+	return [4]uint16{a[0] | b[0], a[1] | b[1], a[2] | b[2], a[3] | b[3]}
+}
+
+// cardPairs appends every two card combination of cards to pairs as rank
+// masks per suit
+func cardPairs(pairs [][4]uint16, cards []Card) [][4]uint16 {
+	for i := 0; i < len(cards); i++ {
+		for j := i + 1; j < len(cards); j++ {
+			pairs = append(pairs, orMasks(cardMasks[cards[i]], cardMasks[cards[j]]))
+		}
+	}
+	return pairs
+}
+
+// cardTriples appends every three card combination of cards to triples as
+// rank masks per suit
+func cardTriples(triples [][4]uint16, cards []Card) [][4]uint16 {
+	for a := 0; a < len(cards); a++ {
+		for b := a + 1; b < len(cards); b++ {
+			ab := orMasks(cardMasks[cards[a]], cardMasks[cards[b]])
+			for c := b + 1; c < len(cards); c++ {
+				triples = append(triples, orMasks(ab, cardMasks[cards[c]]))
+			}
+		}
+	}
+	return triples
+}
+
+// EvaluateOmahaLow returns the best eight-or-better low made from exactly
+// two of the hole cards and exactly three of the board cards. ok is false
+// when no qualifying low can be made.
+func EvaluateOmahaLow(hole, board []Card) (value LowValue, ok bool) {
+	// Only cards of eight or lower matter, as distinct ranks
+	var holeRanks, boardRanks []uint16
+	seen := uint16(0)
+	for _, c := range hole {
+		if r := lowRank(c.Rank()); r >= 0 && seen&(1<<r) == 0 {
+			seen |= 1 << r
+			holeRanks = append(holeRanks, 1<<r)
+		}
+	}
+	seen = 0
+	for _, c := range board {
+		if r := lowRank(c.Rank()); r >= 0 && seen&(1<<r) == 0 {
+			seen |= 1 << r
+			boardRanks = append(boardRanks, 1<<r)
+		}
+	}
+
+	for i := 0; i < len(holeRanks); i++ {
+		for j := i + 1; j < len(holeRanks); j++ {
+			two := holeRanks[i] | holeRanks[j]
+			for a := 0; a < len(boardRanks); a++ {
+				for b := a + 1; b < len(boardRanks); b++ {
+					for c := b + 1; c < len(boardRanks); c++ {
+						mask := two | boardRanks[a] | boardRanks[b] | boardRanks[c]
+						if v, qualifies := bestLow(mask); qualifies && (!ok || v < value) {
+							value, ok = v, true
+						}
+					}
+				}
+			}
+		}
+	}
+	return value, ok
+}
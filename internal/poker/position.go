@@ -0,0 +1,65 @@
+package poker
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Positions names the players in seats by their seat relative to the
+// button: BTN, SB, BB, then UTG onwards with the last seats before the
+// button named LJ, HJ and CO. Heads-up the button is also the small
+// blind. The names are returned in the order of seats, or nil without a
+// button or with fewer than two players.
+func Positions(seats []int, buttonSeat int) []string {
+	if buttonSeat == 0 || len(seats) < 2 {
+		return nil
+	}
+
+	order := make([]int, len(seats))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return seats[order[i]] < seats[order[j]]
+	})
+
+	// Rotate so the button (or the first seat after it) comes first
+	start := 0
+	for i, idx := range order {
+		if seats[idx] >= buttonSeat {
+			start = i
+			break
+		}
+	}
+	order = append(order[start:], order[:start]...)
+
+	names := positionNames(len(seats))
+	positions := make([]string, len(seats))
+	for i, idx := range order {
+		positions[idx] = names[i]
+	}
+	return positions
+}
+
+// positionNames returns position names for a table of n players, starting
+// at the button
+func positionNames(n int) []string {
+	if n == 2 {
+		return []string{"BTN", "BB"}
+	}
+
+	names := []string{"BTN", "SB", "BB"}
+	rest := n - 3
+	late := []string{"LJ", "HJ", "CO"}
+	if rest-1 < len(late) {
+		late = late[len(late)-max(rest-1, 0):]
+	}
+	for i := 0; i < rest-len(late); i++ {
+		if i == 0 {
+			names = append(names, "UTG")
+		} else {
+			names = append(names, fmt.Sprintf("UTG+%d", i))
+		}
+	}
+	return append(names, late...)
+}
@@ -0,0 +1,385 @@
+// Package replay reconstructs the table state of a stored hand after each
+// action, for stepping through the hand in a visual replayer.
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aniki/internal/database"
+	"aniki/internal/hand_history"
+)
+
+// Snapshot kinds that are not player actions
+const (
+	KindStart    = "start"    // players seated with their starting stacks
+	KindDeal     = "deal"     // board cards of a new street dealt
+	KindReturn   = "returned" // uncalled bet given back
+	KindShowdown = "shows"    // hole cards revealed
+	KindCollect  = "collected"
+)
+
+// boardSizes is the number of board cards dealt by the start of each street
+var boardSizes = map[string]int{"preflop": 0, "flop": 3, "turn": 4, "river": 5}
+
+// streets lists the betting streets in order
+var streets = []string{"preflop", "flop", "turn", "river"}
+
+var (
+	uncalledRegex  = regexp.MustCompile(`^Uncalled bet \(\$?(\d+(?:\.\d+)?)\) returned to (.+)$`)
+	collectedRegex = regexp.MustCompile(`^(.+?) collected \$?(\d+(?:\.\d+)?) from`)
+)
+
+// Seat is the state of one player
+type Seat struct {
+	Seat     int      `json:"seat"`
+	Name     string   `json:"name"`
+	Position string   `json:"position"`
+	Hero     bool     `json:"hero"`
+	Stack    float64  `json:"stack"`    // chips behind
+	Bet      float64  `json:"bet"`      // chips put in on the current street
+	Invested float64  `json:"invested"` // chips put in over the whole hand
+	Won      float64  `json:"won"`      // chips collected from the pot
+	Folded   bool     `json:"folded"`
+	AllIn    bool     `json:"all_in"`
+	Cards    []string `json:"cards"` // known hole cards
+}
+
+// Snapshot is the table state after one step of the hand
+type Snapshot struct {
+	Step       int      `json:"step"`
+	Kind       string   `json:"kind"` // a player action such as "raises", or one of the Kind constants
+	Street     string   `json:"street"`
+	Player     string   `json:"player,omitempty"` // player who acted
+	Amount     float64  `json:"amount"`
+	AllIn      bool     `json:"all_in"`
+	Text       string   `json:"text"` // description of the step, e.g. "Hero raises to 6"
+	Board      []string `json:"board"`
+	Pot        float64  `json:"pot"`         // chips in the middle, including current bets
+	CurrentBet float64  `json:"current_bet"` // highest bet of the street
+	ToAct      string   `json:"to_act"`      // next player to act, "" when betting is over
+	Seats      []Seat   `json:"seats"`
+}
+
+// table is the running state while replaying
+type table struct {
+	actions   []database.Action
+	board     []string
+	street    string
+	dealt     int
+	pot       float64
+	seats     []Seat
+	index     map[string]int
+	snapshots []Snapshot
+}
+
+// Build returns the snapshots of a hand loaded with its players and
+// actions, starting with the players seated before the blinds
+func Build(hand *database.Hand) ([]Snapshot, error) {
+	t := &table{
+		actions: append([]database.Action(nil), hand.Actions...),
+		street:  "preflop",
+		index:   make(map[string]int, len(hand.Players)),
+	}
+	sort.SliceStable(t.actions, func(i, j int) bool { return t.actions[i].Sequence < t.actions[j].Sequence })
+
+	if err := decodeCards(hand.Board, &t.board); err != nil {
+		return nil, fmt.Errorf("failed to decode board: %w", err)
+	}
+	var holeCards []string
+	if err := decodeCards(hand.HoleCards, &holeCards); err != nil {
+		return nil, fmt.Errorf("failed to decode hole cards: %w", err)
+	}
+
+	players := append([]database.Player(nil), hand.Players...)
+	sort.Slice(players, func(i, j int) bool { return players[i].Seat < players[j].Seat })
+	var shown []database.Player
+	for _, player := range players {
+		seat := Seat{
+			Seat:     player.Seat,
+			Name:     player.Name,
+			Position: player.Position,
+			Hero:     player.Name == hand.HeroName,
+			Stack:    player.Stack,
+			Cards:    []string{},
+		}
+		if seat.Hero && len(holeCards) > 0 {
+			seat.Cards = holeCards
+		}
+		if player.Cards != "" && !seat.Hero {
+			shown = append(shown, player)
+		}
+		t.index[player.Name] = len(t.seats)
+		t.seats = append(t.seats, seat)
+	}
+
+	t.record(Snapshot{Kind: KindStart, Text: "Players are seated"}, 0)
+
+	for i, action := range t.actions {
+		if action.Street != t.street {
+			t.deal(action.Street, i)
+		}
+		if err := t.apply(action); err != nil {
+			return nil, err
+		}
+		t.record(Snapshot{
+			Kind:   action.Action,
+			Player: action.PlayerName,
+			Amount: action.Amount,
+			AllIn:  action.AllIn,
+			Text:   describe(action),
+		}, i+1)
+	}
+
+	returned, collected := parseResults(hand.RawText)
+	if hand_history.NewOHHParser().CanParse(hand.RawText) {
+		returned, collected = ohhResults(hand.RawText, t.actions, t.seats)
+	}
+	for _, r := range returned {
+		seat := t.seat(r.name)
+		if seat == nil {
+			continue
+		}
+		seat.Stack += r.amount
+		seat.Bet -= r.amount
+		seat.Invested -= r.amount
+		seat.AllIn = false
+		t.pot -= r.amount
+		t.record(Snapshot{
+			Kind:   KindReturn,
+			Player: r.name,
+			Amount: r.amount,
+			Text:   fmt.Sprintf("Uncalled bet (%s) returned to %s", formatAmount(r.amount), r.name),
+		}, len(t.actions))
+	}
+
+	// Board cards dealt after everyone is all-in
+	for _, street := range streets {
+		if boardSizes[street] > t.dealt && boardSizes[street] <= len(t.board) {
+			t.deal(street, len(t.actions))
+		}
+	}
+
+	for _, player := range shown {
+		t.street = "showdown"
+		seat := t.seat(player.Name)
+		if err := decodeCards(player.Cards, &seat.Cards); err != nil {
+			return nil, fmt.Errorf("failed to decode cards of %s: %w", player.Name, err)
+		}
+		t.record(Snapshot{
+			Kind:   KindShowdown,
+			Player: player.Name,
+			Text:   fmt.Sprintf("%s shows %s", player.Name, strings.Join(seat.Cards, " ")),
+		}, len(t.actions))
+	}
+
+	for _, c := range collected {
+		seat := t.seat(c.name)
+		if seat == nil {
+			continue
+		}
+		seat.Stack += c.amount
+		seat.Won += c.amount
+		t.pot -= c.amount
+		t.record(Snapshot{
+			Kind:   KindCollect,
+			Player: c.name,
+			Amount: c.amount,
+			Text:   fmt.Sprintf("%s collects %s", c.name, formatAmount(c.amount)),
+		}, len(t.actions))
+	}
+
+	return t.snapshots, nil
+}
+
+// seat returns the seat of the named player, nil when not seated
+func (t *table) seat(name string) *Seat {
+	i, ok := t.index[name]
+	if !ok {
+		return nil
+	}
+	return &t.seats[i]
+}
+
+// deal starts street, revealing its board cards and clearing the bets.
+// next is the index of the first action of the street.
+func (t *table) deal(street string, next int) {
+	t.street = street
+	for i := range t.seats {
+		t.seats[i].Bet = 0
+	}
+
+	size := boardSizes[street]
+	if size > len(t.board) {
+		size = len(t.board)
+	}
+	if size <= t.dealt {
+		return
+	}
+	cards := t.board[t.dealt:size]
+	t.dealt = size
+	t.record(Snapshot{Kind: KindDeal, Text: fmt.Sprintf("%s: %s", strings.ToUpper(street[:1])+street[1:], strings.Join(cards, " "))}, next)
+}
+
+// apply updates the table with a player action. Raise amounts are street
+// totals ("raises 4 to 6"); other amounts are the chips added.
+func (t *table) apply(action database.Action) error {
+	seat := t.seat(action.PlayerName)
+	if seat == nil {
+		return fmt.Errorf("action by unknown player %s", action.PlayerName)
+	}
+
+	var added float64
+	switch action.Action {
+	case "folds":
+		seat.Folded = true
+	case "posts the ante":
+		added = action.Amount
+	case "raises":
+		added = action.Amount - seat.Bet
+		seat.Bet = action.Amount
+	case "posts small blind", "posts big blind", "calls", "bets":
+		added = action.Amount
+		seat.Bet += action.Amount
+	}
+
+	seat.Stack -= added
+	seat.Invested += added
+	t.pot += added
+	if action.AllIn || (added > 0 && seat.Stack <= 0) {
+		seat.AllIn = true
+	}
+	return nil
+}
+
+// record appends a snapshot of the current state. next is the index of
+// the next action, which tells who is to act.
+func (t *table) record(s Snapshot, next int) {
+	s.Step = len(t.snapshots)
+	s.Street = t.street
+	s.Board = append([]string{}, t.board[:t.dealt]...)
+	s.Pot = round(t.pot)
+	s.Seats = make([]Seat, len(t.seats))
+	for i, seat := range t.seats {
+		seat.Stack = round(seat.Stack)
+		seat.Bet = round(seat.Bet)
+		seat.Invested = round(seat.Invested)
+		seat.Cards = append([]string{}, seat.Cards...)
+		if seat.Bet > s.CurrentBet {
+			s.CurrentBet = seat.Bet
+		}
+		s.Seats[i] = seat
+	}
+
+	// Antes and blinds are posted, not acted
+	for ; next < len(t.actions); next++ {
+		if action := t.actions[next]; !strings.HasPrefix(action.Action, "posts") {
+			if action.Street == t.street {
+				s.ToAct = action.PlayerName
+			}
+			break
+		}
+	}
+
+	t.snapshots = append(t.snapshots, s)
+}
+
+// result is an amount given to a player at the end of the hand
+type result struct {
+	name   string
+	amount float64
+}
+
+// parseResults reads the uncalled bets and the pots collected from the raw
+// hand history, which are not stored as actions
+func parseResults(raw string) (returned, collected []result) {
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "*** SUMMARY ***") {
+			break
+		}
+		if m := uncalledRegex.FindStringSubmatch(line); m != nil {
+			amount, _ := strconv.ParseFloat(m[1], 64)
+			returned = append(returned, result{m[2], amount})
+		} else if m := collectedRegex.FindStringSubmatch(line); m != nil {
+			amount, _ := strconv.ParseFloat(m[2], 64)
+			collected = append(collected, result{m[1], amount})
+		}
+	}
+	return returned, collected
+}
+
+// ohhResults derives the uncalled bets of an Open Hand History hand from
+// its actions and reads the pots collected from its pots, in seat order
+func ohhResults(raw string, actions []database.Action, seats []Seat) (returned, collected []result) {
+	parsed := make([]hand_history.Action, len(actions))
+	for i, a := range actions {
+		parsed[i] = hand_history.Action{PlayerName: a.PlayerName, Action: a.Action, Amount: a.Amount, Street: a.Street}
+	}
+	uncalled := hand_history.UncalledBets(parsed)
+
+	won := map[string]float64{}
+	var doc hand_history.OHH
+	if err := json.Unmarshal([]byte(raw), &doc); err == nil {
+		names := map[int]string{}
+		for _, player := range doc.Hand.Players {
+			names[player.ID] = player.Name
+		}
+		for _, pot := range doc.Hand.Pots {
+			for _, win := range pot.PlayerWins {
+				won[names[win.PlayerID]] += win.WinAmount
+			}
+		}
+	}
+
+	for _, seat := range seats {
+		if amount := uncalled[seat.Name]; amount > 0 {
+			returned = append(returned, result{seat.Name, amount})
+		}
+		if amount := won[seat.Name]; amount > 0 {
+			collected = append(collected, result{seat.Name, amount})
+		}
+	}
+	return returned, collected
+}
+
+// describe returns the hand history wording of an action
+func describe(action database.Action) string {
+	text := action.PlayerName + " " + action.Action
+	switch action.Action {
+	case "folds", "checks":
+	case "raises":
+		text += " to " + formatAmount(action.Amount)
+	default:
+		text += " " + formatAmount(action.Amount)
+	}
+	if action.AllIn {
+		text += " and is all-in"
+	}
+	return text
+}
+
+// decodeCards decodes a JSON array of cards, leaving cards untouched when
+// data is empty
+func decodeCards(data string, cards *[]string) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), cards)
+}
+
+// formatAmount formats chips without trailing zeros
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(round(amount), 'f', -1, 64)
+}
+
+// round removes floating point noise from chip amounts
+func round(amount float64) float64 {
+	// Adding zero turns -0 into 0
+	return math.Round(amount*100)/100 + 0
+}
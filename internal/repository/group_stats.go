@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"fmt"
+
+	"aniki/internal/database"
+)
+
+// groupResult is one row of a grouped aggregate
+type groupResult struct {
+	Grp        string
+	OpponentID int64
+	Hands      int64
+	NetWon     float64
+	EVWon      float64
+	Rake       float64
+	BBWon      float64
+	BBHands    int64
+	Voluntary  int64
+	Raised     int64
+}
+
+// groupColumns aggregates hands together with the players row whose
+// preflop flags are counted
+const groupColumns = `
+	COUNT(*) as hands,
+	COALESCE(SUM(hands.result), 0) as net_won,
+	COALESCE(SUM(COALESCE(hands.all_in_ev, hands.result)), 0) as ev_won,
+	COALESCE(SUM(hands.rake), 0) as rake,
+	COALESCE(SUM(CASE WHEN hands.big_blind > 0 THEN hands.result / hands.big_blind ELSE 0 END), 0) as bb_won,
+	COALESCE(SUM(CASE WHEN hands.big_blind > 0 THEN 1 ELSE 0 END), 0) as bb_hands,
+	COALESCE(SUM(CASE WHEN players.vpip THEN 1 ELSE 0 END), 0) as voluntary,
+	COALESCE(SUM(CASE WHEN players.pfr THEN 1 ELSE 0 END), 0) as raised
+`
+
+// GetGroupStats aggregates the hands matching filter by the hero's
+// position, the stakes or the opponents played against, most hands first,
+// or all together
+func (r *handRepository) GetGroupStats(filter database.HandFilter, group string) ([]database.GroupStats, error) {
+	var results []groupResult
+	var err error
+
+	switch group {
+	case database.GroupPosition, database.GroupStakes:
+		err = applyFilter(r.db.Table("hands"), filter).
+			Joins("LEFT JOIN players ON players.hand_id = hands.id AND players.name = hands.hero_name").
+			Select("hands." + group + " as grp," + groupColumns).
+			Group("hands." + group).
+			Order("hands DESC").
+			Scan(&results).Error
+	case database.GroupTotal:
+		err = applyFilter(r.db.Table("hands"), filter).
+			Joins("LEFT JOIN players ON players.hand_id = hands.id AND players.name = hands.hero_name").
+			Select("'Total' as grp," + groupColumns).
+			Scan(&results).Error
+	case database.GroupOpponent:
+		err = applyFilter(r.db.Table("players"), filter).
+			Joins("JOIN hands ON hands.id = players.hand_id").
+			Where("players.name <> hands.hero_name AND players.opponent_id IS NOT NULL").
+			Select("players.opponent_id as opponent_id," + groupColumns).
+			Group("players.opponent_id").
+			Order("hands DESC").
+			Scan(&results).Error
+		if err == nil {
+			err = r.opponentNames(results)
+		}
+	default:
+		return nil, fmt.Errorf("unknown grouping %q", group)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate hands by %s: %w", group, err)
+	}
+
+	stats := make([]database.GroupStats, len(results))
+	for i, result := range results {
+		s := database.GroupStats{
+			Group:  result.Grp,
+			Hands:  int(result.Hands),
+			NetWon: result.NetWon,
+			EVWon:  result.EVWon,
+			Rake:   result.Rake,
+		}
+		if result.BBHands > 0 {
+			s.BBPer100 = result.BBWon / float64(result.BBHands) * 100
+		}
+		if result.Hands > 0 {
+			s.VPIP = float64(result.Voluntary) / float64(result.Hands) * 100
+			s.PFR = float64(result.Raised) / float64(result.Hands) * 100
+		}
+		stats[i] = s
+	}
+	return stats, nil
+}
+
+// opponentNames sets the group of each result to its opponent's name
+func (r *handRepository) opponentNames(results []groupResult) error {
+	ids := make([]int64, len(results))
+	for i, result := range results {
+		ids[i] = result.OpponentID
+	}
+
+	var opponents []database.Opponent
+	if err := r.db.Where("id IN ?", ids).Find(&opponents).Error; err != nil {
+		return err
+	}
+	names := make(map[int64]string, len(opponents))
+	for _, opponent := range opponents {
+		names[opponent.ID] = opponent.Name
+	}
+	for i := range results {
+		results[i].Grp = names[results[i].OpponentID]
+	}
+	return nil
+}
@@ -1,11 +1,18 @@
 package repository
 
 import (
+	"fmt"
+
 	"aniki/internal/database"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// childBatchSize is the number of players, actions or cards inserted per
+// statement, kept well below SQLite's bound parameter limit
+const childBatchSize = 500
+
 type handRepository struct {
 	db *gorm.DB
 }
@@ -19,9 +26,87 @@ func (r *handRepository) Create(hand *database.Hand) error {
 	return r.db.Create(hand).Error
 }
 
+// CreateBatch inserts hands together with their players, actions and cards
+// in a single transaction, linking players to their opponents. Hands that
+// already exist for the same site are left untouched and counted as
+// skipped.
+func (r *handRepository) CreateBatch(hands []database.Hand) (int, int, error) {
+	inserted := 0
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		inserted = 0
+
+		var created []*database.Hand
+		var players []database.Player
+		var actions []database.Action
+		var cards []database.HandCard
+
+		for i := range hands {
+			hand := &hands[i]
+
+			result := tx.Omit(clause.Associations).
+				Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "site_id"}, {Name: "hand_id"}},
+					DoNothing: true,
+				}).
+				Create(hand)
+			if result.Error != nil {
+				return fmt.Errorf("failed to insert hand %s: %w", hand.HandID, result.Error)
+			}
+			if result.RowsAffected == 0 {
+				continue // Duplicate
+			}
+			inserted++
+			created = append(created, hand)
+
+			for j := range hand.Players {
+				hand.Players[j].HandID = hand.ID
+			}
+			for j := range hand.Actions {
+				hand.Actions[j].HandID = hand.ID
+			}
+			for j := range hand.Cards {
+				hand.Cards[j].HandID = hand.ID
+			}
+			actions = append(actions, hand.Actions...)
+			cards = append(cards, hand.Cards...)
+		}
+
+		if err := linkOpponents(tx, created); err != nil {
+			return err
+		}
+		for _, hand := range created {
+			players = append(players, hand.Players...)
+		}
+
+		if len(players) > 0 {
+			if err := tx.CreateInBatches(players, childBatchSize).Error; err != nil {
+				return fmt.Errorf("failed to insert players: %w", err)
+			}
+		}
+		if len(actions) > 0 {
+			if err := tx.CreateInBatches(actions, childBatchSize).Error; err != nil {
+				return fmt.Errorf("failed to insert actions: %w", err)
+			}
+		}
+		if len(cards) > 0 {
+			if err := tx.CreateInBatches(cards, childBatchSize).Error; err != nil {
+				return fmt.Errorf("failed to insert cards: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return inserted, len(hands) - inserted, nil
+}
+
 func (r *handRepository) FindByID(id int64) (*database.Hand, error) {
 	var hand database.Hand
-	err := r.db.Preload("Site").Preload("Players").Preload("Actions").First(&hand, id).Error
+	err := r.db.Preload("Site").Preload("Players").Preload("Actions").Preload("Cards").First(&hand, id).Error
 	if err == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
@@ -30,8 +115,56 @@ func (r *handRepository) FindByID(id int64) (*database.Hand, error) {
 
 func (r *handRepository) FindAll(filter database.HandFilter) ([]database.Hand, error) {
 	var hands []database.Hand
-	query := r.db.Model(&database.Hand{})
+	query := applyFilter(r.db.Model(&database.Hand{}), filter)
+
+	query = query.Order("date_time DESC")
 
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	err := query.Find(&hands).Error
+	return hands, err
+}
+
+// ForEach calls fn with the hands matching filter, oldest first, in
+// batches of up to size hands loaded with their site, players and
+// actions. The paging fields of filter are ignored.
+func (r *handRepository) ForEach(filter database.HandFilter, size int, fn func(hands []database.Hand) error) error {
+	if size <= 0 {
+		size = 500
+	}
+	for offset := 0; ; offset += size {
+		var hands []database.Hand
+		err := applyFilter(r.db.Model(&database.Hand{}), filter).
+			Preload("Site").
+			Preload("Players", func(db *gorm.DB) *gorm.DB { return db.Order("seat") }).
+			Preload("Actions", func(db *gorm.DB) *gorm.DB { return db.Order("sequence") }).
+			Order("date_time, id").
+			Limit(size).
+			Offset(offset).
+			Find(&hands).Error
+		if err != nil {
+			return fmt.Errorf("failed to load hands: %w", err)
+		}
+		if len(hands) == 0 {
+			return nil
+		}
+		if err := fn(hands); err != nil {
+			return err
+		}
+		if len(hands) < size {
+			return nil
+		}
+	}
+}
+
+// applyFilter adds the conditions of filter, other than paging, to query
+func applyFilter(query *gorm.DB, filter database.HandFilter) *gorm.DB {
 	if filter.SiteID != nil {
 		query = query.Where("site_id = ?", *filter.SiteID)
 	}
@@ -52,18 +185,11 @@ func (r *handRepository) FindAll(filter database.HandFilter) ([]database.Hand, e
 		query = query.Where("date_time <= ?", *filter.DateTo)
 	}
 
-	query = query.Order("date_time DESC")
-
-	if filter.Limit > 0 {
-		query = query.Limit(filter.Limit)
-	}
-
-	if filter.Offset > 0 {
-		query = query.Offset(filter.Offset)
+	if len(filter.Tags) > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM tags WHERE tags.hand_id = hands.id AND tags.name IN ?)", filter.Tags)
 	}
 
-	err := query.Find(&hands).Error
-	return hands, err
+	return query
 }
 
 func (r *handRepository) Exists(siteID int, handID string) (bool, error) {
@@ -78,11 +204,16 @@ func (r *handRepository) GetStats(heroName string) (*database.Stats, error) {
 	type Result struct {
 		TotalHands  int64
 		TotalWon    float64
+		EVWon       float64
+		AllInHands  int64
 		TotalRake   float64
 		BiggestWin  float64
 		BiggestLoss float64
 		HandsWon    int64
 		HandsLost   int64
+		BBWon       float64
+		EVBBWon     float64 `gorm:"column:ev_bb_won"`
+		BBHands     int64
 	}
 
 	var result Result
@@ -91,11 +222,16 @@ func (r *handRepository) GetStats(heroName string) (*database.Stats, error) {
 		Select(`
 			COUNT(*) as total_hands,
 			COALESCE(SUM(result), 0) as total_won,
+			COALESCE(SUM(COALESCE(all_in_ev, result)), 0) as ev_won,
+			COUNT(all_in_ev) as all_in_hands,
 			COALESCE(SUM(rake), 0) as total_rake,
 			COALESCE(MAX(result), 0) as biggest_win,
 			COALESCE(MIN(result), 0) as biggest_loss,
 			COALESCE(SUM(CASE WHEN result > 0 THEN 1 ELSE 0 END), 0) as hands_won,
-			COALESCE(SUM(CASE WHEN result < 0 THEN 1 ELSE 0 END), 0) as hands_lost
+			COALESCE(SUM(CASE WHEN result < 0 THEN 1 ELSE 0 END), 0) as hands_lost,
+			COALESCE(SUM(CASE WHEN big_blind > 0 THEN result / big_blind ELSE 0 END), 0) as bb_won,
+			COALESCE(SUM(CASE WHEN big_blind > 0 THEN COALESCE(all_in_ev, result) / big_blind ELSE 0 END), 0) as ev_bb_won,
+			COALESCE(SUM(CASE WHEN big_blind > 0 THEN 1 ELSE 0 END), 0) as bb_hands
 		`).
 		Scan(&result).Error
 
@@ -105,15 +241,18 @@ func (r *handRepository) GetStats(heroName string) (*database.Stats, error) {
 
 	stats.TotalHands = int(result.TotalHands)
 	stats.TotalWon = result.TotalWon
+	stats.EVWon = result.EVWon
+	stats.AllInHands = int(result.AllInHands)
 	stats.TotalRake = result.TotalRake
 	stats.BiggestWin = result.BiggestWin
 	stats.BiggestLoss = result.BiggestLoss
 	stats.HandsWon = int(result.HandsWon)
 	stats.HandsLost = int(result.HandsLost)
 
-	// Calculate win rate (simplified - would need more context for accurate BB/100)
-	if stats.TotalHands > 0 {
-		stats.WinRate = (stats.TotalWon / float64(stats.TotalHands)) * 100
+	// Hands without a known big blind cannot count towards BB/100
+	if result.BBHands > 0 {
+		stats.WinRate = result.BBWon / float64(result.BBHands) * 100
+		stats.EVWinRate = result.EVBBWon / float64(result.BBHands) * 100
 	}
 
 	return stats, nil
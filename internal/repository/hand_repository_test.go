@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aniki/internal/database"
+)
+
+// newTestDB opens a migrated database in a temporary directory with one
+// site, PokerStars
+func newTestDB(t *testing.T) (*database.DB, *database.Site) {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"), database.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	site := &database.Site{Name: "PokerStars"}
+	if err := NewSiteRepository(db.DB).Create(site); err != nil {
+		t.Fatal(err)
+	}
+	return db, site
+}
+
+// TestCreateBatchConcurrentReads imports batches of hands while readers
+// query through the replica pool. Run with -race; no query may fail with
+// SQLITE_BUSY ("database is locked").
+func TestCreateBatchConcurrentReads(t *testing.T) {
+	db, site := newTestDB(t)
+	hands := NewHandRepository(db.DB)
+
+	const (
+		batches   = 40
+		batchSize = 25
+		readers   = 4
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, batches+readers)
+	done := make(chan struct{})
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for b := 0; b < batches; b++ {
+			batch := make([]database.Hand, batchSize)
+			for i := range batch {
+				n := b*batchSize + i
+				batch[i] = database.Hand{
+					SiteID:   site.ID,
+					HandID:   fmt.Sprint(n),
+					GameType: "Hold'em No Limit",
+					DateTime: start.Add(time.Duration(n) * time.Minute),
+					HeroName: "Hero",
+					Result:   1,
+					Players: []database.Player{
+						{Name: "Hero", Seat: 1, Stack: 100},
+						{Name: fmt.Sprintf("Villain%d", n%10), Seat: 2, Stack: 100},
+					},
+					Actions: []database.Action{
+						{PlayerName: "Hero", Street: "preflop", Action: "raises", Amount: 3, Sequence: 1},
+					},
+				}
+			}
+			if _, _, err := hands.CreateBatch(batch); err != nil {
+				errs <- fmt.Errorf("batch %d: %w", b, err)
+				return
+			}
+		}
+	}()
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := hands.Search(database.HandSearch{Limit: 20}); err != nil {
+					errs <- fmt.Errorf("search: %w", err)
+					return
+				}
+				if _, err := hands.GetStats("Hero"); err != nil {
+					errs <- fmt.Errorf("stats: %w", err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if strings.Contains(err.Error(), "locked") || strings.Contains(err.Error(), "busy") {
+			t.Errorf("SQLITE_BUSY: %v", err)
+		} else {
+			t.Error(err)
+		}
+	}
+
+	var count int64
+	if err := db.Model(&database.Hand{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != batches*batchSize {
+		t.Errorf("got %d hands, want %d", count, batches*batchSize)
+	}
+}
+
+func TestGetStatsWinRate(t *testing.T) {
+	db, site := newTestDB(t)
+	hands := NewHandRepository(db.DB)
+
+	ev := 0.5
+	batch := []database.Hand{
+		// +10bb at 0.5/1 and -5bb at 1/2, the second all-in at +0.5bb EV
+		{SiteID: site.ID, HandID: "1", HeroName: "Hero", BigBlind: 1, Result: 10},
+		{SiteID: site.ID, HandID: "2", HeroName: "Hero", BigBlind: 2, Result: -10, AllInEV: &ev},
+		// No big blind, so left out of BB/100
+		{SiteID: site.ID, HandID: "3", HeroName: "Hero", Result: 100},
+	}
+	if _, _, err := hands.CreateBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := hands.GetStats("Hero")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalHands != 3 {
+		t.Errorf("got %d hands, want 3", stats.TotalHands)
+	}
+	if want := (10.0 - 5) / 2 * 100; stats.WinRate != want {
+		t.Errorf("win rate %v, want %v", stats.WinRate, want)
+	}
+	if want := (10 + 0.25) / 2 * 100; stats.EVWinRate != want {
+		t.Errorf("EV win rate %v, want %v", stats.EVWinRate, want)
+	}
+}
@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"aniki/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// Search returns one page of hands matching search together with the total
+// number of matches
+func (r *handRepository) Search(search database.HandSearch) (*database.HandPage, error) {
+	query, err := applySearch(r.db.Model(&database.Hand{}), search)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &database.HandPage{Hands: []database.Hand{}, Limit: search.Limit, Offset: search.Offset}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count hands: %w", err)
+	}
+
+	query = query.Order("hands.date_time DESC")
+	if search.Limit > 0 {
+		query = query.Limit(search.Limit)
+	}
+	if search.Offset > 0 {
+		query = query.Offset(search.Offset)
+	}
+	if err := query.Find(&page.Hands).Error; err != nil {
+		return nil, fmt.Errorf("failed to search hands: %w", err)
+	}
+
+	return page, nil
+}
+
+// applySearch adds the conditions of search to query
+func applySearch(query *gorm.DB, s database.HandSearch) (*gorm.DB, error) {
+	if s.Site != "" {
+		query = query.Where("hands.site_id IN (SELECT id FROM sites WHERE name = ? COLLATE NOCASE)", s.Site)
+	}
+	if s.HeroName != "" {
+		query = query.Where("hands.hero_name = ?", s.HeroName)
+	}
+	if s.GameType != "" {
+		query = query.Where(`hands.game_type LIKE ? ESCAPE '\'`, likeContains(s.GameType))
+	}
+	if len(s.Stakes) > 0 {
+		query = query.Where("hands.stakes IN ?", s.Stakes)
+	}
+	if len(s.Tables) > 0 {
+		query = anyOf(query, `hands.table_name LIKE ? ESCAPE '\'`, likeAll(s.Tables))
+	}
+	if len(s.Positions) > 0 {
+		query = query.Where("hands.position IN ?", s.Positions)
+	}
+	if s.DateFrom != nil {
+		query = query.Where("hands.date_time >= ?", *s.DateFrom)
+	}
+	if s.DateTo != nil {
+		query = query.Where("hands.date_time <= ?", *s.DateTo)
+	}
+
+	if len(s.Cards) > 0 {
+		var clauses []string
+		var args []interface{}
+		for _, cards := range s.Cards {
+			clause, clauseArgs := cardsClause(cards)
+			clauses = append(clauses, clause)
+			args = append(args, clauseArgs...)
+		}
+		query = query.Where("("+strings.Join(clauses, " OR ")+")", args...)
+	}
+
+	for _, texture := range s.Flop {
+		query = query.Where(textureClause(texture))
+	}
+	for _, card := range s.Board {
+		query = query.Where(boardCardClause, rankOf(card[0]), card[1:2])
+	}
+
+	if len(s.Streets) > 0 {
+		clauses := make([]string, 0, len(s.Streets))
+		for _, street := range s.Streets {
+			clauses = append(clauses, streetClause(street))
+		}
+		query = query.Where("(" + strings.Join(clauses, " OR ") + ")")
+	}
+
+	for _, villain := range s.Villains {
+		if strings.Contains(villain, "*") {
+			query = query.Where(`EXISTS (SELECT 1 FROM players WHERE players.hand_id = hands.id AND players.name <> hands.hero_name AND players.name LIKE ? ESCAPE '\')`,
+				strings.ReplaceAll(escapeLike(villain), "*", "%"))
+		} else {
+			query = query.Where("EXISTS (SELECT 1 FROM players WHERE players.hand_id = hands.id AND players.name <> hands.hero_name AND players.name = ? COLLATE NOCASE)",
+				villain)
+		}
+	}
+
+	if len(s.Tags) > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM tags WHERE tags.hand_id = hands.id AND tags.name IN ?)", s.Tags)
+	}
+
+	if s.AllIn != nil {
+		exists := "EXISTS (SELECT 1 FROM actions WHERE actions.hand_id = hands.id AND actions.all_in = true)"
+		if *s.AllIn {
+			query = query.Where(exists)
+		} else {
+			query = query.Where("NOT " + exists)
+		}
+	}
+
+	var err error
+	if query, err = applyNumbers(query, "hands.result", s.Result); err != nil {
+		return nil, err
+	}
+	if query, err = applyNumbers(query, "hands.total_pot", s.Pot); err != nil {
+		return nil, err
+	}
+
+	for _, text := range s.Text {
+		query = query.Where(`(hands.hand_id = ? OR hands.table_name LIKE ? ESCAPE '\')`, text, likeContains(text))
+	}
+
+	return query, nil
+}
+
+// applyNumbers adds numeric comparisons on column. Amounts in big blinds
+// are compared against column divided by the hand's big blind, so hands
+// with an unknown big blind never match them.
+func applyNumbers(query *gorm.DB, column string, conds []database.NumberCondition) (*gorm.DB, error) {
+	for _, cond := range conds {
+		switch cond.Op {
+		case "<", "<=", ">", ">=", "=":
+		default:
+			return nil, fmt.Errorf("unsupported comparison %q", cond.Op)
+		}
+
+		expr := column
+		if cond.BigBlinds {
+			expr = column + " / NULLIF(hands.big_blind, 0)"
+		}
+		query = query.Where(expr+" "+cond.Op+" ?", cond.Value)
+	}
+	return query, nil
+}
+
+// cardsClause matches the hero's hole cards against a hand class, a group
+// of classes or exact cards
+func cardsClause(cards string) (string, []interface{}) {
+	switch {
+	case cards == "pair":
+		return "length(hands.hand_class) = 2", nil
+	case cards == "suited":
+		return "hands.hand_class LIKE '__s'", nil
+	case cards == "offsuit":
+		return "hands.hand_class LIKE '__o'", nil
+	case len(cards) == 4:
+		return "(" + holeCardClause + " AND " + holeCardClause + ")", []interface{}{
+			rankOf(cards[0]), cards[1:2], rankOf(cards[2]), cards[3:4],
+		}
+	case len(cards) == 2 && cards[0] != cards[1]:
+		// Either suitedness
+		return "hands.hand_class IN (?, ?)", []interface{}{cards + "s", cards + "o"}
+	default:
+		return "hands.hand_class = ?", []interface{}{cards}
+	}
+}
+
+// holeCardClause matches hands where the hero holds a card of rank and suit
+const holeCardClause = "EXISTS (SELECT 1 FROM hand_cards WHERE hand_cards.hand_id = hands.id AND hand_cards.kind = 'hole' AND hand_cards.player_name = hands.hero_name AND hand_cards.rank = ? AND hand_cards.suit = ?)"
+
+// boardCardClause matches hands with a card of rank and suit on the board
+const boardCardClause = "EXISTS (SELECT 1 FROM hand_cards WHERE hand_cards.hand_id = hands.id AND hand_cards.kind = 'board' AND hand_cards.rank = ? AND hand_cards.suit = ?)"
+
+// textureClause matches flops with texture
+func textureClause(texture string) string {
+	switch texture {
+	case "monotone":
+		return "hands.flop_suits = 1"
+	case "twotone":
+		return "hands.flop_suits = 2"
+	case "rainbow":
+		return "hands.flop_suits = 3"
+	case "paired":
+		return "hands.flop_paired = true"
+	case "unpaired":
+		return "hands.flop_suits > 0 AND hands.flop_paired = false"
+	case "connected":
+		return "hands.flop_connected = true"
+	default:
+		return "1 = 0"
+	}
+}
+
+// rankOf returns the stored rank (2-14) of a rank character
+func rankOf(c byte) int {
+	return strings.IndexByte("23456789TJQKA", c) + 2
+}
+
+// streetClause matches hands that reached street. The board is used
+// rather than actions so hands that were all-in earlier still count.
+func streetClause(street string) string {
+	switch street {
+	case "flop":
+		return "hands.board LIKE '%,%,%'"
+	case "turn":
+		return "hands.board LIKE '%,%,%,%'"
+	case "river":
+		return "hands.board LIKE '%,%,%,%,%'"
+	case "showdown":
+		return showdownClause
+	default:
+		return "1 = 1"
+	}
+}
+
+// anyOf adds clause once per value, combined with OR
+func anyOf(query *gorm.DB, clause string, values []string) *gorm.DB {
+	clauses := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		clauses[i] = clause
+		args[i] = v
+	}
+	return query.Where("("+strings.Join(clauses, " OR ")+")", args...)
+}
+
+// likeAll turns each value into a substring pattern with likeContains
+func likeAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = likeContains(v)
+	}
+	return out
+}
+
+// likeEscaper escapes the LIKE wildcards, for patterns used with
+// ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike returns s as a LIKE pattern matching it literally
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// likeContains returns a LIKE pattern matching values that contain s
+func likeContains(s string) string {
+	return "%" + escapeLike(s) + "%"
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"aniki/internal/database"
+)
+
+func TestSearchMatchesWildcardsLiterally(t *testing.T) {
+	db, site := newTestDB(t)
+	hands := NewHandRepository(db.DB)
+
+	// Each hand is played against one villain at one table
+	villains := []string{"big_fish", "bigXfish", "50%er", "500er"}
+	batch := make([]database.Hand, len(villains))
+	for i, villain := range villains {
+		batch[i] = database.Hand{
+			SiteID:    site.ID,
+			HandID:    fmt.Sprint(i + 1),
+			TableName: "Table " + villain,
+			DateTime:  time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC),
+			HeroName:  "Hero",
+			Players:   []database.Player{{Name: "Hero", Seat: 1}, {Name: villain, Seat: 2}},
+		}
+	}
+	if _, _, err := hands.CreateBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		search database.HandSearch
+		want   []string // hand IDs
+	}{
+		{database.HandSearch{Villains: []string{"big_*"}}, []string{"1"}},
+		{database.HandSearch{Villains: []string{"*_fish"}}, []string{"1"}},
+		{database.HandSearch{Villains: []string{"big*"}}, []string{"2", "1"}},
+		{database.HandSearch{Villains: []string{"50%*"}}, []string{"3"}},
+		{database.HandSearch{Tables: []string{"big_"}}, []string{"1"}},
+		{database.HandSearch{Tables: []string{"0%"}}, []string{"3"}},
+		{database.HandSearch{Text: []string{"_fish"}}, []string{"1"}},
+	}
+	for _, tt := range tests {
+		tt.search.Limit = 10
+		page, err := hands.Search(tt.search)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, hand := range page.Hands {
+			got = append(got, hand.HandID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%+v: got hands %v, want %v", tt.search, got, tt.want)
+		}
+	}
+}
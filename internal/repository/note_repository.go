@@ -0,0 +1,422 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"aniki/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// notesExportVersion is the version of the NotesExport format
+const notesExportVersion = 1
+
+type noteRepository struct {
+	db *gorm.DB
+}
+
+// NewNoteRepository creates a new note repository instance
+func NewNoteRepository(db *gorm.DB) NoteRepository {
+	return &noteRepository{db: db}
+}
+
+// targetColumn returns the column referencing the kind of target
+func targetColumn(target database.Target) (string, error) {
+	switch target.Kind {
+	case database.TargetOpponent:
+		return "opponent_id", nil
+	case database.TargetHand:
+		return "hand_id", nil
+	}
+	return "", fmt.Errorf("unknown target kind %q", target.Kind)
+}
+
+// whereTarget restricts query to rows attached to target
+func whereTarget(query *gorm.DB, target database.Target) (*gorm.DB, error) {
+	column, err := targetColumn(target)
+	if err != nil {
+		return nil, err
+	}
+	return query.Where(column+" = ?", target.ID), nil
+}
+
+// setTarget points the opponent and hand IDs at target
+func setTarget(target database.Target, opponentID, handID **int64) {
+	id := target.ID
+	if target.Kind == database.TargetOpponent {
+		*opponentID = &id
+	} else {
+		*handID = &id
+	}
+}
+
+// Find returns the notes, tags and color label of target
+func (r *noteRepository) Find(target database.Target) (*database.Annotations, error) {
+	annotations := &database.Annotations{Target: target, Notes: []database.Note{}, Tags: []string{}}
+
+	query, err := whereTarget(r.db.Model(&database.Note{}), target)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.Order("created_at").Find(&annotations.Notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to load notes: %w", err)
+	}
+
+	query, _ = whereTarget(r.db.Model(&database.Tag{}), target)
+	if err := query.Order("name").Pluck("name", &annotations.Tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+
+	var labels []database.ColorLabel
+	query, _ = whereTarget(r.db.Model(&database.ColorLabel{}), target)
+	if err := query.Limit(1).Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to load color label: %w", err)
+	}
+	if len(labels) > 0 {
+		annotations.Color = labels[0].Color
+	}
+
+	return annotations, nil
+}
+
+// AddNote attaches a new note to target
+func (r *noteRepository) AddNote(target database.Target, text string) (*database.Note, error) {
+	if _, err := targetColumn(target); err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("note is empty")
+	}
+
+	note := &database.Note{Text: text}
+	setTarget(target, &note.OpponentID, &note.HandID)
+	if err := r.db.Create(note).Error; err != nil {
+		return nil, fmt.Errorf("failed to add note: %w", err)
+	}
+	return note, nil
+}
+
+// UpdateNote replaces the text of a note
+func (r *noteRepository) UpdateNote(id int64, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("note is empty")
+	}
+	return r.db.Model(&database.Note{ID: id}).Update("text", text).Error
+}
+
+func (r *noteRepository) DeleteNote(id int64) error {
+	return r.db.Delete(&database.Note{}, id).Error
+}
+
+// SearchNotes returns notes containing text, newest first, with their
+// opponent or hand loaded
+func (r *noteRepository) SearchNotes(text string, limit int) ([]database.Note, error) {
+	var notes []database.Note
+	query := r.db.Preload("Opponent").Preload("Hand").
+		Where(`text LIKE ? ESCAPE '\'`, likeContains(text)).
+		Order("updated_at DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	err := query.Find(&notes).Error
+	return notes, err
+}
+
+// AddTag tags target with name. Tagging twice with the same name has no
+// effect.
+func (r *noteRepository) AddTag(target database.Target, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("tag is empty")
+	}
+	_, err := addTag(r.db, target, name)
+	return err
+}
+
+// addTag tags target with name unless it already is, reporting whether a
+// tag was added
+func addTag(db *gorm.DB, target database.Target, name string) (bool, error) {
+	query, err := whereTarget(db.Model(&database.Tag{}), target)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	if err := query.Where("name = ?", name).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check tag: %w", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	tag := database.Tag{Name: name}
+	setTarget(target, &tag.OpponentID, &tag.HandID)
+	if err := db.Create(&tag).Error; err != nil {
+		return false, fmt.Errorf("failed to add tag: %w", err)
+	}
+	return true, nil
+}
+
+func (r *noteRepository) RemoveTag(target database.Target, name string) error {
+	query, err := whereTarget(r.db, target)
+	if err != nil {
+		return err
+	}
+	return query.Where("name = ?", name).Delete(&database.Tag{}).Error
+}
+
+// TagNames returns every tag name in use, alphabetically
+func (r *noteRepository) TagNames() ([]string, error) {
+	names := []string{}
+	err := r.db.Model(&database.Tag{}).Distinct("name").Order("name").Pluck("name", &names).Error
+	return names, err
+}
+
+// SetColor marks target with color. An empty color removes the label.
+func (r *noteRepository) SetColor(target database.Target, color string) error {
+	return setColor(r.db, target, strings.TrimSpace(color))
+}
+
+func setColor(db *gorm.DB, target database.Target, color string) error {
+	column, err := targetColumn(target)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(column+" = ?", target.ID).Delete(&database.ColorLabel{}).Error; err != nil {
+			return fmt.Errorf("failed to remove color label: %w", err)
+		}
+		if color == "" {
+			return nil
+		}
+		label := database.ColorLabel{Color: color}
+		setTarget(target, &label.OpponentID, &label.HandID)
+		if err := tx.Create(&label).Error; err != nil {
+			return fmt.Errorf("failed to set color label: %w", err)
+		}
+		return nil
+	})
+}
+
+// Export returns every note, tag and color label in portable form
+func (r *noteRepository) Export() (*database.NotesExport, error) {
+	export := &database.NotesExport{
+		Version:   notesExportVersion,
+		Exported:  time.Now(),
+		Opponents: []database.ExportedOpponent{},
+		Hands:     []database.ExportedHand{},
+	}
+
+	opponents := map[int64]*database.ExportedOpponent{}
+	hands := map[int64]*database.ExportedHand{}
+
+	// entry returns the export entry of the target of a row, loading the
+	// opponent or hand the first time it is seen
+	entry := func(opponentID, handID *int64) (color *string, tags *[]string, notes *[]database.ExportedNote, err error) {
+		if opponentID != nil {
+			e, ok := opponents[*opponentID]
+			if !ok {
+				var opponent database.Opponent
+				if err := r.db.Preload("Site").First(&opponent, *opponentID).Error; err != nil {
+					return nil, nil, nil, fmt.Errorf("failed to load opponent %d: %w", *opponentID, err)
+				}
+				e = &database.ExportedOpponent{Site: siteName(opponent.Site), Name: opponent.Name}
+				opponents[*opponentID] = e
+			}
+			return &e.Color, &e.Tags, &e.Notes, nil
+		}
+
+		e, ok := hands[*handID]
+		if !ok {
+			var hand database.Hand
+			if err := r.db.Preload("Site").Select("id", "site_id", "hand_id").First(&hand, *handID).Error; err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to load hand %d: %w", *handID, err)
+			}
+			e = &database.ExportedHand{Site: siteName(hand.Site), HandID: hand.HandID}
+			hands[*handID] = e
+		}
+		return &e.Color, &e.Tags, &e.Notes, nil
+	}
+
+	var notes []database.Note
+	if err := r.db.Order("created_at").Find(&notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to load notes: %w", err)
+	}
+	for _, note := range notes {
+		_, _, list, err := entry(note.OpponentID, note.HandID)
+		if err != nil {
+			return nil, err
+		}
+		*list = append(*list, database.ExportedNote{Text: note.Text, CreatedAt: note.CreatedAt})
+	}
+
+	var tags []database.Tag
+	if err := r.db.Order("name").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tags: %w", err)
+	}
+	for _, tag := range tags {
+		_, list, _, err := entry(tag.OpponentID, tag.HandID)
+		if err != nil {
+			return nil, err
+		}
+		*list = append(*list, tag.Name)
+	}
+
+	var labels []database.ColorLabel
+	if err := r.db.Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to load color labels: %w", err)
+	}
+	for _, label := range labels {
+		color, _, _, err := entry(label.OpponentID, label.HandID)
+		if err != nil {
+			return nil, err
+		}
+		*color = label.Color
+	}
+
+	for _, e := range opponents {
+		export.Opponents = append(export.Opponents, *e)
+	}
+	for _, e := range hands {
+		export.Hands = append(export.Hands, *e)
+	}
+	sort.Slice(export.Opponents, func(i, j int) bool {
+		a, b := export.Opponents[i], export.Opponents[j]
+		return a.Site < b.Site || a.Site == b.Site && a.Name < b.Name
+	})
+	sort.Slice(export.Hands, func(i, j int) bool {
+		a, b := export.Hands[i], export.Hands[j]
+		return a.Site < b.Site || a.Site == b.Site && a.HandID < b.HandID
+	})
+	return export, nil
+}
+
+// Import adds the notes, tags and color labels of an export. Opponents
+// not seen yet are created so their notes are ready when they are; hands
+// that are not in the database are skipped. Notes whose text the target
+// already has are not added again.
+func (r *noteRepository) Import(export *database.NotesExport) (*database.NotesImport, error) {
+	if export.Version > notesExportVersion {
+		return nil, fmt.Errorf("unsupported notes export version %d", export.Version)
+	}
+
+	result := &database.NotesImport{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		*result = database.NotesImport{}
+		sites := map[string]int{}
+
+		siteID := func(name string) (int, error) {
+			if id, ok := sites[name]; ok {
+				return id, nil
+			}
+			site := database.Site{Name: name, Enabled: true}
+			if err := tx.Where("name = ?", name).FirstOrCreate(&site).Error; err != nil {
+				return 0, fmt.Errorf("failed to find site %s: %w", name, err)
+			}
+			sites[name] = site.ID
+			return site.ID, nil
+		}
+
+		for _, e := range export.Opponents {
+			id, err := siteID(e.Site)
+			if err != nil {
+				return err
+			}
+			// Opponents created here have not been seen, so their seen
+			// times are left empty for the first import to fill in
+			opponent := database.Opponent{SiteID: id, Name: e.Name}
+			err = tx.Where("site_id = ? AND name = ?", id, e.Name).Attrs(opponent).
+				Omit("FirstSeen", "LastSeen").FirstOrCreate(&opponent).Error
+			if err != nil {
+				return fmt.Errorf("failed to find opponent %s: %w", e.Name, err)
+			}
+			if opponent.MergedIntoID != nil {
+				opponent.ID = *opponent.MergedIntoID
+			}
+
+			target := database.Target{Kind: database.TargetOpponent, ID: opponent.ID}
+			if err := importAnnotations(tx, target, e.Color, e.Tags, e.Notes, result); err != nil {
+				return err
+			}
+		}
+
+		for _, e := range export.Hands {
+			id, err := siteID(e.Site)
+			if err != nil {
+				return err
+			}
+			var handIDs []int64
+			if err := tx.Model(&database.Hand{}).Where("site_id = ? AND hand_id = ?", id, e.HandID).Pluck("id", &handIDs).Error; err != nil {
+				return fmt.Errorf("failed to find hand %s: %w", e.HandID, err)
+			}
+			if len(handIDs) == 0 {
+				result.Skipped++
+				continue
+			}
+
+			target := database.Target{Kind: database.TargetHand, ID: handIDs[0]}
+			if err := importAnnotations(tx, target, e.Color, e.Tags, e.Notes, result); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importAnnotations adds imported annotations to target, counting them in
+// result
+func importAnnotations(tx *gorm.DB, target database.Target, color string, tags []string, notes []database.ExportedNote, result *database.NotesImport) error {
+	if color != "" {
+		if err := setColor(tx, target, color); err != nil {
+			return err
+		}
+		result.Colors++
+	}
+
+	for _, name := range tags {
+		added, err := addTag(tx, target, name)
+		if err != nil {
+			return err
+		}
+		if added {
+			result.Tags++
+		}
+	}
+
+	for _, n := range notes {
+		query, _ := whereTarget(tx.Model(&database.Note{}), target)
+		var count int64
+		if err := query.Where("text = ?", n.Text).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check note: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		note := database.Note{Text: n.Text, CreatedAt: n.CreatedAt}
+		setTarget(target, &note.OpponentID, &note.HandID)
+		if err := tx.Create(&note).Error; err != nil {
+			return fmt.Errorf("failed to import note: %w", err)
+		}
+		result.Notes++
+	}
+	return nil
+}
+
+// siteName returns the name of a loaded site, or "" if it is missing
+func siteName(site *database.Site) string {
+	if site == nil {
+		return ""
+	}
+	return site.Name
+}
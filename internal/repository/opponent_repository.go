@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"aniki/internal/database"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type opponentRepository struct {
+	db *gorm.DB
+}
+
+// NewOpponentRepository creates a new opponent repository instance
+func NewOpponentRepository(db *gorm.DB) OpponentRepository {
+	return &opponentRepository{db: db}
+}
+
+func (r *opponentRepository) FindByID(id int64) (*database.Opponent, error) {
+	var opponent database.Opponent
+	err := r.db.Preload("Site").First(&opponent, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &opponent, err
+}
+
+func (r *opponentRepository) FindByName(siteID int, name string) (*database.Opponent, error) {
+	var opponent database.Opponent
+	err := r.db.Preload("Site").Where("site_id = ? AND name = ?", siteID, name).First(&opponent).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &opponent, err
+}
+
+// notHero leaves out the opponents rows of hero names. Heroes are linked
+// like every other player so the HUD can show their stats, but they are
+// not opponents.
+const notHero = "NOT EXISTS (SELECT 1 FROM hands WHERE hands.site_id = opponents.site_id AND hands.hero_name = opponents.name)"
+
+// FindAll returns opponents whose name contains name, most recently seen
+// first. Aliases merged into another opponent and heroes are left out.
+func (r *opponentRepository) FindAll(name string, limit, offset int) ([]database.Opponent, error) {
+	var opponents []database.Opponent
+	query := r.db.Preload("Site").Where("merged_into_id IS NULL").Where(notHero)
+
+	if name != "" {
+		query = query.Where(`name LIKE ? ESCAPE '\'`, likeContains(name))
+	}
+
+	query = query.Order("last_seen DESC")
+
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	err := query.Find(&opponents).Error
+	return opponents, err
+}
+
+// opponentHands restricts a hand query to hands the opponent played with
+// a hero other than itself
+func opponentHands(query *gorm.DB, id int64) *gorm.DB {
+	return query.Where("EXISTS (SELECT 1 FROM players WHERE players.hand_id = hands.id AND players.opponent_id = ? AND players.name <> hands.hero_name)", id)
+}
+
+// FindHands returns a page of the hands the opponent played with the hero,
+// newest first
+func (r *opponentRepository) FindHands(id int64, limit, offset int) (*database.HandPage, error) {
+	page := &database.HandPage{Hands: []database.Hand{}, Limit: limit, Offset: offset}
+
+	var total int64
+	if err := opponentHands(r.db.Model(&database.Hand{}), id).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count hands: %w", err)
+	}
+	page.Total = total
+
+	query := opponentHands(r.db.Model(&database.Hand{}), id).Order("date_time DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+	if err := query.Find(&page.Hands).Error; err != nil {
+		return nil, fmt.Errorf("failed to load hands: %w", err)
+	}
+	return page, nil
+}
+
+// GetStats aggregates the hands the opponent played with the hero. It
+// returns nil when there is no such opponent.
+func (r *opponentRepository) GetStats(id int64) (*database.OpponentStats, error) {
+	opponent, err := r.FindByID(id)
+	if err != nil || opponent == nil {
+		return nil, err
+	}
+
+	stats := &database.OpponentStats{Opponent: *opponent, Aliases: []string{}, LastSeen: opponent.LastSeen}
+
+	if err := r.db.Model(&database.Opponent{}).Where("merged_into_id = ?", id).Order("name").
+		Pluck("name", &stats.Aliases).Error; err != nil {
+		return nil, fmt.Errorf("failed to load aliases: %w", err)
+	}
+
+	type Result struct {
+		Hands     int64
+		Voluntary int64
+		Raised    int64
+		Showdowns int64
+		HeroWon   float64
+	}
+
+	var result Result
+	err = r.db.Table("players").
+		Joins("JOIN hands ON hands.id = players.hand_id").
+		Where("players.opponent_id = ? AND players.name <> hands.hero_name", id).
+		Select(`
+			COUNT(*) as hands,
+			COALESCE(SUM(CASE WHEN players.vpip THEN 1 ELSE 0 END), 0) as voluntary,
+			COALESCE(SUM(CASE WHEN players.pfr THEN 1 ELSE 0 END), 0) as raised,
+			COALESCE(SUM(CASE WHEN players.cards <> '' THEN 1 ELSE 0 END), 0) as showdowns,
+			COALESCE(SUM(hands.result), 0) as hero_won
+		`).
+		Scan(&result).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate hands: %w", err)
+	}
+
+	stats.Hands = int(result.Hands)
+	stats.Showdowns = int(result.Showdowns)
+	stats.HeroWon = result.HeroWon
+	if result.Hands > 0 {
+		stats.VPIP = float64(result.Voluntary) / float64(result.Hands) * 100
+		stats.PFR = float64(result.Raised) / float64(result.Hands) * 100
+	}
+
+	return stats, nil
+}
+
+// PlayerStats returns the preflop statistics of the named players on a
+// site over every hand they played, keyed by name. Hands of merged
+// aliases count towards the opponent they were merged into. Players
+// without hands are left out.
+func (r *opponentRepository) PlayerStats(siteID int, names []string) (map[string]database.PlayerStats, error) {
+	type Result struct {
+		Name           string
+		Hands          int64
+		Voluntary      int64
+		Raised         int64
+		ThreeBetChance int64
+		ThreeBet       int64
+	}
+
+	var results []Result
+	err := r.db.Table("opponents").
+		Joins("JOIN players ON players.opponent_id = COALESCE(opponents.merged_into_id, opponents.id)").
+		Where("opponents.site_id = ? AND opponents.name IN ?", siteID, names).
+		Group("opponents.name").
+		Select(`
+			opponents.name as name,
+			COUNT(*) as hands,
+			COALESCE(SUM(CASE WHEN players.vpip THEN 1 ELSE 0 END), 0) as voluntary,
+			COALESCE(SUM(CASE WHEN players.pfr THEN 1 ELSE 0 END), 0) as raised,
+			COALESCE(SUM(CASE WHEN players.three_bet_chance THEN 1 ELSE 0 END), 0) as three_bet_chance,
+			COALESCE(SUM(CASE WHEN players.three_bet THEN 1 ELSE 0 END), 0) as three_bet
+		`).
+		Scan(&results).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate player stats: %w", err)
+	}
+
+	stats := make(map[string]database.PlayerStats, len(results))
+	for _, result := range results {
+		s := database.PlayerStats{Hands: int(result.Hands)}
+		if result.Hands > 0 {
+			s.VPIP = float64(result.Voluntary) / float64(result.Hands) * 100
+			s.PFR = float64(result.Raised) / float64(result.Hands) * 100
+		}
+		if result.ThreeBetChance > 0 {
+			s.ThreeBet = float64(result.ThreeBet) / float64(result.ThreeBetChance) * 100
+		}
+		stats[result.Name] = s
+	}
+	return stats, nil
+}
+
+// Merge makes the aliases part of the target opponent. Their hands are
+// moved to the target, and later hands under an alias name are linked to
+// the target as well.
+func (r *opponentRepository) Merge(targetID int64, aliasIDs []int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var target database.Opponent
+		if err := tx.First(&target, targetID).Error; err != nil {
+			return fmt.Errorf("failed to find opponent %d: %w", targetID, err)
+		}
+		if target.MergedIntoID != nil {
+			return fmt.Errorf("opponent %s is an alias and cannot be merged into", target.Name)
+		}
+		if err := checkNotHero(tx, &target); err != nil {
+			return err
+		}
+
+		for _, aliasID := range aliasIDs {
+			if aliasID == targetID {
+				continue
+			}
+			var alias database.Opponent
+			if err := tx.First(&alias, aliasID).Error; err != nil {
+				return fmt.Errorf("failed to find opponent %d: %w", aliasID, err)
+			}
+			if err := checkNotHero(tx, &alias); err != nil {
+				return err
+			}
+
+			// Aliases of the alias follow it into the target
+			err := tx.Model(&database.Opponent{}).
+				Where("id = ? OR merged_into_id = ?", aliasID, aliasID).
+				Update("merged_into_id", targetID).Error
+			if err != nil {
+				return fmt.Errorf("failed to merge opponent %s: %w", alias.Name, err)
+			}
+			err = tx.Model(&database.Player{}).
+				Where("opponent_id IN (SELECT id FROM opponents WHERE merged_into_id = ?)", targetID).
+				Update("opponent_id", targetID).Error
+			if err != nil {
+				return fmt.Errorf("failed to move hands of opponent %s: %w", alias.Name, err)
+			}
+
+			if err := moveAnnotations(tx, aliasID, targetID); err != nil {
+				return fmt.Errorf("failed to move notes of opponent %s: %w", alias.Name, err)
+			}
+
+			if err := touchOpponent(tx, targetID, alias.FirstSeen, alias.LastSeen); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// checkNotHero returns an error when opponent is the hero of any hand
+func checkNotHero(tx *gorm.DB, opponent *database.Opponent) error {
+	var count int64
+	err := tx.Model(&database.Hand{}).
+		Where("site_id = ? AND hero_name = ?", opponent.SiteID, opponent.Name).
+		Limit(1).Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check opponent %s: %w", opponent.Name, err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%s is a hero and cannot be merged", opponent.Name)
+	}
+	return nil
+}
+
+// moveAnnotations moves the notes, tags and color label of an alias to
+// the opponent it is merged into. Tags the target already has and the
+// alias's color, when the target has one, are dropped.
+func moveAnnotations(tx *gorm.DB, aliasID, targetID int64) error {
+	statements := []string{
+		"UPDATE notes SET opponent_id = @target WHERE opponent_id = @alias",
+		"DELETE FROM tags WHERE opponent_id = @alias AND name IN (SELECT name FROM tags WHERE opponent_id = @target)",
+		"UPDATE tags SET opponent_id = @target WHERE opponent_id = @alias",
+		"DELETE FROM color_labels WHERE opponent_id = @alias AND EXISTS (SELECT 1 FROM color_labels WHERE opponent_id = @target)",
+		"UPDATE color_labels SET opponent_id = @target WHERE opponent_id = @alias",
+	}
+	for _, stmt := range statements {
+		if err := tx.Exec(stmt, sql.Named("alias", aliasID), sql.Named("target", targetID)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchOpponent widens the first and last seen times of an opponent to
+// include first and last
+func touchOpponent(tx *gorm.DB, id int64, first, last time.Time) error {
+	err := tx.Exec("UPDATE opponents SET first_seen = CASE WHEN first_seen IS NULL OR first_seen > ? THEN ? ELSE first_seen END, last_seen = CASE WHEN last_seen IS NULL OR last_seen < ? THEN ? ELSE last_seen END WHERE id = ?",
+		first, first, last, last, id).Error
+	if err != nil {
+		return fmt.Errorf("failed to update opponent %d: %w", id, err)
+	}
+	return nil
+}
+
+// opponentKey identifies an opponent while linking players
+type opponentKey struct {
+	siteID int
+	name   string
+}
+
+// linkOpponents sets the opponent of every player of hands, creating
+// opponents seen for the first time. Players of aliases are linked to the
+// opponent the alias was merged into.
+func linkOpponents(tx *gorm.DB, hands []*database.Hand) error {
+	type seen struct{ first, last time.Time }
+	times := map[opponentKey]*seen{}
+	for _, hand := range hands {
+		for _, player := range hand.Players {
+			key := opponentKey{hand.SiteID, player.Name}
+			if s, ok := times[key]; ok {
+				if hand.DateTime.Before(s.first) {
+					s.first = hand.DateTime
+				}
+				if hand.DateTime.After(s.last) {
+					s.last = hand.DateTime
+				}
+			} else {
+				times[key] = &seen{hand.DateTime, hand.DateTime}
+			}
+		}
+	}
+
+	ids := make(map[opponentKey]int64, len(times))
+	for key, s := range times {
+		opponent := database.Opponent{SiteID: key.siteID, Name: key.name, FirstSeen: s.first, LastSeen: s.last}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "site_id"}, {Name: "name"}},
+			DoNothing: true,
+		}).Create(&opponent).Error
+		if err != nil {
+			return fmt.Errorf("failed to create opponent %s: %w", key.name, err)
+		}
+
+		if err := tx.Where("site_id = ? AND name = ?", key.siteID, key.name).First(&opponent).Error; err != nil {
+			return fmt.Errorf("failed to find opponent %s: %w", key.name, err)
+		}
+		id := opponent.ID
+		if opponent.MergedIntoID != nil {
+			id = *opponent.MergedIntoID
+		}
+		if err := touchOpponent(tx, id, s.first, s.last); err != nil {
+			return err
+		}
+		ids[key] = id
+	}
+
+	for _, hand := range hands {
+		for j := range hand.Players {
+			id := ids[opponentKey{hand.SiteID, hand.Players[j].Name}]
+			hand.Players[j].OpponentID = &id
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"aniki/internal/database"
+)
+
+func TestHeroIsNotAnOpponent(t *testing.T) {
+	db, site := newTestDB(t)
+	hands := NewHandRepository(db.DB)
+	opponents := NewOpponentRepository(db.DB)
+
+	hand := database.Hand{
+		SiteID:   site.ID,
+		HandID:   "1",
+		DateTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		HeroName: "Hero",
+		Players: []database.Player{
+			{Name: "Hero", Seat: 1, Stack: 100},
+			{Name: "Alice", Seat: 2, Stack: 100},
+			{Name: "Bob", Seat: 3, Stack: 100},
+		},
+	}
+	if _, _, err := hands.CreateBatch([]database.Hand{hand}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := opponents.FindAll("", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, o := range list {
+		names = append(names, o.Name)
+	}
+	if len(names) != 2 || names[0] == "Hero" || names[1] == "Hero" {
+		t.Errorf("got opponents %v, want Alice and Bob", names)
+	}
+
+	hero, err := opponents.FindByName(site.ID, "Hero")
+	if err != nil || hero == nil {
+		t.Fatalf("hero row: %v, %v", hero, err)
+	}
+	alice, err := opponents.FindByName(site.ID, "Alice")
+	if err != nil || alice == nil {
+		t.Fatalf("Alice: %v, %v", alice, err)
+	}
+	if err := opponents.Merge(alice.ID, []int64{hero.ID}); err == nil {
+		t.Error("merged the hero into Alice")
+	}
+	if err := opponents.Merge(hero.ID, []int64{alice.ID}); err == nil {
+		t.Error("merged Alice into the hero")
+	}
+}
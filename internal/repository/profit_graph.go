@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"aniki/internal/database"
+)
+
+// showdownClause matches hands that went to showdown, where some player's
+// cards were shown. It holds for every hand history format.
+const showdownClause = `EXISTS (
+	SELECT 1 FROM players WHERE players.hand_id = hands.id AND players.cards IS NOT NULL AND players.cards <> ''
+)`
+
+// showdownColumn is 1 when the hero was still in the hand at showdown
+const showdownColumn = `CASE WHEN ` + showdownClause + ` AND NOT EXISTS (
+	SELECT 1 FROM actions WHERE actions.hand_id = hands.id AND actions.player_name = hands.hero_name AND actions.action = 'folds'
+) THEN 1 ELSE 0 END AS showdown`
+
+// graphHand holds the columns of a hand needed for the winnings graph
+type graphHand struct {
+	DateTime time.Time
+	Result   float64
+	AllInEV  *float64
+	Showdown bool
+}
+
+// GetProfitGraph returns the cumulative winnings of the hands matching
+// filter, oldest first, with one point per hand or per day, week or month.
+// Paging fields of the filter are ignored.
+func (r *handRepository) GetProfitGraph(filter database.HandFilter, interval string) (*database.ProfitGraph, error) {
+	if interval == "" {
+		interval = database.GraphPerHand
+	}
+	if bucketStart(time.Now(), interval).IsZero() {
+		return nil, fmt.Errorf("unknown graph interval %q", interval)
+	}
+
+	rows, err := applyFilter(r.db.Model(&database.Hand{}), filter).
+		Select("hands.date_time", "hands.result", "hands.all_in_ev", showdownColumn).
+		Order("hands.date_time, hands.id").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hands: %w", err)
+	}
+	defer rows.Close()
+
+	graph := &database.ProfitGraph{Interval: interval, Points: []database.GraphPoint{}}
+	var total database.GraphPoint
+	for rows.Next() {
+		var hand graphHand
+		if err := r.db.ScanRows(rows, &hand); err != nil {
+			return nil, err
+		}
+
+		total.Hands++
+		total.NetWon += hand.Result
+		if hand.AllInEV != nil {
+			total.EVWon += *hand.AllInEV
+		} else {
+			total.EVWon += hand.Result
+		}
+		if hand.Showdown {
+			total.Showdown += hand.Result
+		} else {
+			total.NonShowdown += hand.Result
+		}
+
+		// Hands of the same bucket update its point in place
+		total.Time = bucketStart(hand.DateTime, interval)
+		if n := len(graph.Points); interval != database.GraphPerHand && n > 0 && graph.Points[n-1].Time.Equal(total.Time) {
+			graph.Points[n-1] = total
+		} else {
+			graph.Points = append(graph.Points, total)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// bucketStart returns the start of the interval containing t in local
+// time, t itself for per hand graphs, or the zero time for an unknown
+// interval. Weeks start on Monday.
+func bucketStart(t time.Time, interval string) time.Time {
+	t = t.Local()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch interval {
+	case database.GraphPerHand:
+		return t
+	case database.GraphDay:
+		return day
+	case database.GraphWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case database.GraphMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	return time.Time{}
+}
@@ -19,13 +19,23 @@ type SiteRepository interface {
 // HandRepository defines the interface for hand operations
 type HandRepository interface {
 	Create(hand *database.Hand) error
+	CreateBatch(hands []database.Hand) (inserted int, skipped int, err error)
 	FindByID(id int64) (*database.Hand, error)
 	FindAll(filter database.HandFilter) ([]database.Hand, error)
+	ForEach(filter database.HandFilter, size int, fn func(hands []database.Hand) error) error
+	Search(search database.HandSearch) (*database.HandPage, error)
 	Exists(siteID int, handID string) (bool, error)
 	GetStats(heroName string) (*database.Stats, error)
+	GetProfitGraph(filter database.HandFilter, interval string) (*database.ProfitGraph, error)
+	GetGroupStats(filter database.HandFilter, group string) ([]database.GroupStats, error)
 	Delete(id int64) error
 }
 
+// SessionRepository defines the interface for deriving sessions from hands
+type SessionRepository interface {
+	FindAll(heroName string, gap time.Duration) ([]database.Session, error)
+}
+
 // PlayerRepository defines the interface for player operations
 type PlayerRepository interface {
 	Create(player *database.Player) error
@@ -33,20 +43,36 @@ type PlayerRepository interface {
 	Delete(id int64) error
 }
 
+// OpponentRepository defines the interface for opponent operations
+type OpponentRepository interface {
+	FindByID(id int64) (*database.Opponent, error)
+	FindByName(siteID int, name string) (*database.Opponent, error)
+	FindAll(name string, limit, offset int) ([]database.Opponent, error)
+	FindHands(id int64, limit, offset int) (*database.HandPage, error)
+	GetStats(id int64) (*database.OpponentStats, error)
+	PlayerStats(siteID int, names []string) (map[string]database.PlayerStats, error)
+	Merge(targetID int64, aliasIDs []int64) error
+}
+
+// NoteRepository defines the interface for notes, tags and color labels on
+// opponents and hands
+type NoteRepository interface {
+	Find(target database.Target) (*database.Annotations, error)
+	AddNote(target database.Target, text string) (*database.Note, error)
+	UpdateNote(id int64, text string) error
+	DeleteNote(id int64) error
+	SearchNotes(text string, limit int) ([]database.Note, error)
+	AddTag(target database.Target, name string) error
+	RemoveTag(target database.Target, name string) error
+	TagNames() ([]string, error)
+	SetColor(target database.Target, color string) error
+	Export() (*database.NotesExport, error)
+	Import(export *database.NotesExport) (*database.NotesImport, error)
+}
+
 // ActionRepository defines the interface for action operations
 type ActionRepository interface {
 	Create(action *database.Action) error
 	FindByHandID(handID int64) ([]database.Action, error)
 	Delete(id int64) error
 }
-
-// HandFilter is used for querying hands
-type HandFilter struct {
-	SiteID   *int
-	HeroName string
-	GameType string
-	DateFrom *time.Time
-	DateTo   *time.Time
-	Limit    int
-	Offset   int
-}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"aniki/internal/database"
+
+	"gorm.io/gorm"
+)
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new session repository instance
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// sessionHand holds the columns of a hand needed for sessions
+type sessionHand struct {
+	DateTime  time.Time
+	TableName string
+	Stakes    string
+	Result    float64
+	AllInEV   *float64
+	Rake      float64
+	BigBlind  float64
+}
+
+// FindAll groups the hero's hands into sessions, newest first. A new
+// session starts whenever more than gap passes between two hands on any
+// table.
+func (r *sessionRepository) FindAll(heroName string, gap time.Duration) ([]database.Session, error) {
+	rows, err := r.db.Model(&database.Hand{}).
+		Select("date_time", "table_name", "stakes", "result", "all_in_ev", "rake", "big_blind").
+		Where("hero_name = ?", heroName).
+		Order("date_time").
+		Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hands: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []database.Session
+	var current *sessionBuilder
+	for rows.Next() {
+		var hand sessionHand
+		if err := r.db.ScanRows(rows, &hand); err != nil {
+			return nil, err
+		}
+		if current == nil || hand.DateTime.Sub(current.End) > gap {
+			if current != nil {
+				sessions = append(sessions, current.finish())
+			}
+			current = newSessionBuilder(heroName, hand.DateTime)
+		}
+		current.add(hand)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		sessions = append(sessions, current.finish())
+	}
+
+	// Newest first
+	for i, j := 0, len(sessions)-1; i < j; i, j = i+1, j-1 {
+		sessions[i], sessions[j] = sessions[j], sessions[i]
+	}
+	return sessions, nil
+}
+
+// sessionBuilder accumulates the hands of one session
+type sessionBuilder struct {
+	database.Session
+	tables   map[string]bool
+	stakes   map[string]bool
+	bigBlind float64 // sum of results in big blinds
+	bbHands  int     // hands with a known big blind
+}
+
+func newSessionBuilder(heroName string, start time.Time) *sessionBuilder {
+	return &sessionBuilder{
+		Session: database.Session{HeroName: heroName, Start: start, End: start},
+		tables:  map[string]bool{},
+		stakes:  map[string]bool{},
+	}
+}
+
+func (b *sessionBuilder) add(hand sessionHand) {
+	b.End = hand.DateTime
+	b.Hands++
+	b.NetWon += hand.Result
+	b.Rake += hand.Rake
+	if hand.AllInEV != nil {
+		b.EVWon += *hand.AllInEV
+	} else {
+		b.EVWon += hand.Result
+	}
+	if hand.BigBlind > 0 {
+		b.bigBlind += hand.Result / hand.BigBlind
+		b.bbHands++
+	}
+	if hand.TableName != "" && !b.tables[hand.TableName] {
+		b.tables[hand.TableName] = true
+		b.Tables = append(b.Tables, hand.TableName)
+	}
+	if hand.Stakes != "" && !b.stakes[hand.Stakes] {
+		b.stakes[hand.Stakes] = true
+		b.Stakes = append(b.Stakes, hand.Stakes)
+	}
+}
+
+func (b *sessionBuilder) finish() database.Session {
+	s := b.Session
+	duration := s.End.Sub(s.Start)
+	s.DurationMinutes = duration.Minutes()
+	if duration > 0 {
+		s.HandsPerHour = float64(s.Hands) / duration.Hours()
+	}
+	if b.bbHands > 0 {
+		s.BBPer100 = b.bigBlind / float64(b.bbHands) * 100
+	}
+	return s
+}
@@ -32,9 +32,11 @@ func (r *siteRepository) FindByID(id int) (*database.Site, error) {
 	return &site, err
 }
 
+// FindByName ignores case, as parsers name their site in lower case
+// ("pokerstars") while configured sites are capitalized ("PokerStars")
 func (r *siteRepository) FindByName(name string) (*database.Site, error) {
 	var site database.Site
-	err := r.db.Where("name = ?", name).First(&site).Error
+	err := r.db.Where("name = ? COLLATE NOCASE", name).First(&site).Error
 	if err == gorm.ErrRecordNotFound {
 		return nil, nil
 	}
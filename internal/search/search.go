@@ -0,0 +1,384 @@
+// Package search parses the hand search language into a database.HandSearch.
+//
+// A query is a list of terms separated by spaces, all of which must match:
+//
+//	pos:BTN cards:AKs result<-50bb street:river villain:"some player" allin:true
+//	cards:pair flop:monotone,connected board:Ah
+//	tag:bluff,review
+//
+// Terms are key:value or key<op>value with op one of < <= > >= =. Values
+// containing spaces are quoted, and list keys accept comma separated
+// alternatives (pos:BTN,CO), except flop: and board: where every texture
+// and card must hold. A term without a key matches the hand ID or table
+// name.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"aniki/internal/database"
+	"aniki/internal/poker"
+)
+
+// dateLayout is the format of date values
+const dateLayout = "2006-01-02"
+
+// streets are the values accepted by street:, in the order they are dealt
+var streets = []string{"preflop", "flop", "turn", "river", "showdown"}
+
+// textures are the values accepted by flop:
+var textures = []string{"monotone", "twotone", "rainbow", "paired", "unpaired", "connected"}
+
+// cardGroups are the values accepted by cards: besides hand classes and
+// exact cards
+var cardGroups = []string{"pair", "suited", "offsuit"}
+
+// term is a single key/operator/value triple of a query
+type term struct {
+	key   string
+	op    string
+	value string // without quotes
+	list  string // value with its quotes, for splitList
+	pos   int    // offset in the query, for error messages
+}
+
+// ErrSyntax is wrapped by every error Parse returns, telling invalid
+// queries apart from failures to run them
+var ErrSyntax = errors.New("invalid search")
+
+// Parse compiles query into a hand search
+func Parse(query string) (database.HandSearch, error) {
+	var s database.HandSearch
+
+	terms, err := tokenize(query)
+	if err != nil {
+		return s, fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+
+	for _, t := range terms {
+		if err := apply(&s, t); err != nil {
+			return s, fmt.Errorf("%w: at %d: %w", ErrSyntax, t.pos+1, err)
+		}
+	}
+	return s, nil
+}
+
+// apply adds the condition of t to s
+func apply(s *database.HandSearch, t term) error {
+	if t.key == "" {
+		s.Text = append(s.Text, t.value)
+		return nil
+	}
+
+	switch t.key {
+	case "result", "won", "pot":
+		cond, err := parseNumber(t)
+		if err != nil {
+			return err
+		}
+		if t.key == "pot" {
+			s.Pot = append(s.Pot, cond)
+		} else {
+			s.Result = append(s.Result, cond)
+		}
+		return nil
+	case "date":
+		return applyDate(s, t)
+	}
+
+	if t.op != ":" && t.op != "=" {
+		return fmt.Errorf("%s does not support %s", t.key, t.op)
+	}
+	if t.value == "" {
+		return fmt.Errorf("%s needs a value", t.key)
+	}
+
+	switch t.key {
+	case "site":
+		s.Site = t.value
+	case "hero":
+		s.HeroName = t.value
+	case "game":
+		s.GameType = t.value
+	case "stakes":
+		s.Stakes = append(s.Stakes, splitList(t.list)...)
+	case "table":
+		s.Tables = append(s.Tables, splitList(t.list)...)
+	case "pos", "position":
+		for _, pos := range splitList(t.list) {
+			s.Positions = append(s.Positions, strings.ToUpper(pos))
+		}
+	case "cards", "hand":
+		for _, cards := range splitList(t.list) {
+			normalized, err := normalizeCards(cards)
+			if err != nil {
+				return err
+			}
+			s.Cards = append(s.Cards, normalized)
+		}
+	case "flop":
+		for _, texture := range splitList(t.list) {
+			texture = strings.ReplaceAll(strings.ToLower(texture), "-", "")
+			if !isTexture(texture) {
+				return fmt.Errorf("unknown flop texture %q, expected one of %s", texture, strings.Join(textures, ", "))
+			}
+			s.Flop = append(s.Flop, texture)
+		}
+	case "board":
+		for _, card := range splitList(t.list) {
+			c, err := poker.ParseCard(card)
+			if err != nil {
+				return err
+			}
+			s.Board = append(s.Board, c.String())
+		}
+	case "street":
+		for _, street := range splitList(t.list) {
+			street = strings.ToLower(street)
+			if !isStreet(street) {
+				return fmt.Errorf("unknown street %q, expected one of %s", street, strings.Join(streets, ", "))
+			}
+			s.Streets = append(s.Streets, street)
+		}
+	case "villain", "vs":
+		s.Villains = append(s.Villains, splitList(t.list)...)
+	case "tag":
+		s.Tags = append(s.Tags, splitList(t.list)...)
+	case "allin":
+		b, err := parseBool(t.value)
+		if err != nil {
+			return err
+		}
+		s.AllIn = &b
+	default:
+		return fmt.Errorf("unknown key %q", t.key)
+	}
+	return nil
+}
+
+// applyDate sets the date range from a date term. date:2026-01-02 and
+// date=2026-01-02 match that whole day.
+func applyDate(s *database.HandSearch, t term) error {
+	day, err := time.ParseInLocation(dateLayout, t.value, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", t.value)
+	}
+	endOfDay := day.Add(24*time.Hour - time.Nanosecond)
+
+	switch t.op {
+	case ":", "=":
+		s.DateFrom, s.DateTo = &day, &endOfDay
+	case ">=":
+		s.DateFrom = &day
+	case ">":
+		next := endOfDay.Add(time.Nanosecond)
+		s.DateFrom = &next
+	case "<=":
+		s.DateTo = &endOfDay
+	case "<":
+		before := day.Add(-time.Nanosecond)
+		s.DateTo = &before
+	}
+	return nil
+}
+
+// parseNumber parses an amount such as 50, $1.5 or -50bb
+func parseNumber(t term) (database.NumberCondition, error) {
+	cond := database.NumberCondition{Op: t.op}
+	if cond.Op == ":" {
+		cond.Op = "="
+	}
+
+	value := strings.ToLower(t.value)
+	if strings.HasSuffix(value, "bb") {
+		cond.BigBlinds = true
+		value = strings.TrimSuffix(value, "bb")
+	}
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "$")
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return cond, fmt.Errorf("invalid amount %q for %s", t.value, t.key)
+	}
+	if negative {
+		n = -n
+	}
+	cond.Value = n
+	return cond, nil
+}
+
+// parseBool accepts true/false, yes/no and 1/0
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "1":
+		return true, nil
+	case "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// normalizeCards validates a hand class ("AKs", "AKo", "AK", "QQ"), a
+// pair of exact cards ("AhKh") or a group ("pair", "suited", "offsuit"),
+// returning it with ranks upper case and high to low, and suits lower case
+func normalizeCards(cards string) (string, error) {
+	if group := strings.TrimSuffix(strings.ToLower(cards), "s"); contains(cardGroups, group) {
+		return group, nil
+	}
+
+	cards = strings.ReplaceAll(cards, "10", "T")
+	if len(cards) < 2 || len(cards) > 4 {
+		return "", fmt.Errorf("invalid cards %q", cards)
+	}
+
+	if len(cards) == 4 {
+		// Exact cards
+		out := []byte{upperRank(cards[0]), lowerSuit(cards[1]), upperRank(cards[2]), lowerSuit(cards[3])}
+		if !isRank(out[0]) || !isSuit(out[1]) || !isRank(out[2]) || !isSuit(out[3]) {
+			return "", fmt.Errorf("invalid cards %q", cards)
+		}
+		if rankIndex(out[0]) < rankIndex(out[2]) {
+			out = []byte{out[2], out[3], out[0], out[1]}
+		}
+		return string(out), nil
+	}
+
+	out := []byte{upperRank(cards[0]), upperRank(cards[1])}
+	if !isRank(out[0]) || !isRank(out[1]) {
+		return "", fmt.Errorf("invalid cards %q", cards)
+	}
+	if rankIndex(out[0]) < rankIndex(out[1]) {
+		out[0], out[1] = out[1], out[0]
+	}
+	if len(cards) == 3 {
+		kind := lowerSuit(cards[2])
+		if (kind != 's' && kind != 'o') || out[0] == out[1] {
+			return "", fmt.Errorf("invalid cards %q", cards)
+		}
+		out = append(out, kind)
+	}
+	return string(out), nil
+}
+
+func upperRank(c byte) byte { return byte(unicode.ToUpper(rune(c))) }
+func lowerSuit(c byte) byte { return byte(unicode.ToLower(rune(c))) }
+func isRank(c byte) bool    { return rankIndex(c) >= 0 }
+func rankIndex(c byte) int  { return strings.IndexByte("23456789TJQKA", c) }
+func isSuit(c byte) bool    { return strings.IndexByte("cdhs", c) >= 0 }
+
+func isStreet(street string) bool   { return contains(streets, street) }
+func isTexture(texture string) bool { return contains(textures, texture) }
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated value, dropping empty entries.
+// Commas inside quotes do not split, and the quotes are removed.
+func splitList(value string) []string {
+	var out []string
+	var item strings.Builder
+	quoted := false
+	flush := func() {
+		if v := strings.TrimSpace(item.String()); v != "" {
+			out = append(out, v)
+		}
+		item.Reset()
+	}
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			flush()
+		default:
+			item.WriteRune(r)
+		}
+	}
+	flush()
+	return out
+}
+
+// tokenize splits query into terms, honouring double quotes
+func tokenize(query string) ([]term, error) {
+	var terms []term
+	runes := []rune(query)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		var raw strings.Builder
+		quoted := false
+		for ; i < len(runes); i++ {
+			r := runes[i]
+			if r == '"' {
+				quoted = !quoted
+				raw.WriteRune(r)
+				continue
+			}
+			if unicode.IsSpace(r) && !quoted {
+				break
+			}
+			raw.WriteRune(r)
+		}
+		if quoted {
+			return nil, fmt.Errorf("at %d: unterminated quote", start+1)
+		}
+
+		t, err := splitTerm(raw.String())
+		if err != nil {
+			return nil, fmt.Errorf("at %d: %w", start+1, err)
+		}
+		t.pos = start
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// splitTerm splits a raw term into key, operator and unquoted value
+func splitTerm(raw string) (term, error) {
+	if strings.HasPrefix(raw, `"`) {
+		return term{value: unquote(raw), list: raw}, nil
+	}
+
+	i := strings.IndexAny(raw, ":<>=")
+	if i < 0 {
+		return term{value: raw}, nil
+	}
+	if i == 0 {
+		return term{}, fmt.Errorf("missing key in %q", raw)
+	}
+
+	op := raw[i : i+1]
+	if (op == "<" || op == ">") && i+1 < len(raw) && raw[i+1] == '=' {
+		op += "="
+	}
+
+	value := raw[i+len(op):]
+	return term{
+		key:   strings.ToLower(raw[:i]),
+		op:    op,
+		value: unquote(value),
+		list:  value,
+	}, nil
+}
+
+// unquote removes the double quotes from a value
+func unquote(value string) string {
+	return strings.ReplaceAll(value, `"`, "")
+}
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"aniki/internal/database"
+)
+
+func TestNormalizeCards(t *testing.T) {
+	tests := []struct {
+		cards string
+		want  string // "" when invalid
+	}{
+		{"AKs", "AKs"},
+		{"KAs", "AKs"},
+		{"ako", "AKo"},
+		{"KA", "AK"},
+		{"T9", "T9"},
+		{"910s", "T9s"},
+		{"qq", "QQ"},
+		{"AhKh", "AhKh"},
+		{"KdAs", "AsKd"},
+		{"2c3c", "3c2c"},
+		{"pairs", "pair"},
+		{"Suited", "suited"},
+		{"offsuit", "offsuit"},
+		{"QQs", ""},
+		{"AKx", ""},
+		{"A", ""},
+		{"AKQJT", ""},
+		{"AhKx", ""},
+		{"1K", ""},
+	}
+	for _, tt := range tests {
+		got, err := normalizeCards(tt.cards)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: got %q, want an error", tt.cards, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.cards, got, err, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	yes := true
+	tests := []struct {
+		query string
+		want  database.HandSearch
+	}{
+		{"cards:KAs,QQ", database.HandSearch{Cards: []string{"AKs", "QQ"}}},
+		{"pos:btn,Co", database.HandSearch{Positions: []string{"BTN", "CO"}}},
+		{"position:SB", database.HandSearch{Positions: []string{"SB"}}},
+		{"result<-50bb", database.HandSearch{Result: []database.NumberCondition{{Op: "<", Value: -50, BigBlinds: true}}}},
+		{"won>=$1.5", database.HandSearch{Result: []database.NumberCondition{{Op: ">=", Value: 1.5}}}},
+		{"pot:100BB", database.HandSearch{Pot: []database.NumberCondition{{Op: "=", Value: 100, BigBlinds: true}}}},
+		{`villain:"some player"`, database.HandSearch{Villains: []string{"some player"}}},
+		{`vs:"a b",c_d*`, database.HandSearch{Villains: []string{"a b", "c_d*"}}},
+		{"street:River flop:two-tone,paired board:Ah,10c", database.HandSearch{
+			Streets: []string{"river"}, Flop: []string{"twotone", "paired"}, Board: []string{"Ah", "Tc"},
+		}},
+		{"allin:yes tag:bluff", database.HandSearch{AllIn: &yes, Tags: []string{"bluff"}}},
+		{"  12345  table:Alpha ", database.HandSearch{Text: []string{"12345"}, Tables: []string{"Alpha"}}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	s, err := Parse("date:2026-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.DateFrom == nil || s.DateTo == nil || s.DateFrom.Day() != 2 || s.DateTo.Day() != 2 || !s.DateTo.After(*s.DateFrom) {
+		t.Errorf("date:2026-01-02 gave %v to %v", s.DateFrom, s.DateTo)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string // part of the error message
+	}{
+		{"cards:AKx", "invalid cards"},
+		{"result<abc", "invalid amount"},
+		{"result<bb", "invalid amount"},
+		{`villain:"unterminated`, "unterminated quote"},
+		{"street:fifth", "unknown street"},
+		{"flop:wet", "unknown flop texture"},
+		{"board:Zz", "invalid card"},
+		{"allin:maybe", "invalid boolean"},
+		{"date:yesterday", "invalid date"},
+		{"colour:red", "unknown key"},
+		{"pos<BTN", "does not support"},
+		{"pos:", "needs a value"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want one containing %q", tt.query, err, tt.err)
+		}
+		if !errors.Is(err, ErrSyntax) {
+			t.Errorf("%s: error %v is not ErrSyntax", tt.query, err)
+		}
+	}
+}
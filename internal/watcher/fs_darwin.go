@@ -0,0 +1,30 @@
+package watcher
+
+import "syscall"
+
+// Names of file systems that do not reliably deliver FSEvents/kqueue events
+var networkFSTypes = map[string]bool{
+	"nfs":     true,
+	"smbfs":   true,
+	"afpfs":   true,
+	"webdav":  true,
+	"osxfuse": true,
+	"macfuse": true,
+}
+
+// isNetworkFS reports whether path resides on a network file system
+func isNetworkFS(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false
+	}
+
+	name := make([]byte, 0, len(st.Fstypename))
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	return networkFSTypes[string(name)]
+}
@@ -0,0 +1,23 @@
+package watcher
+
+import "syscall"
+
+// Magic numbers of file systems that do not reliably deliver inotify events
+// for changes made by other hosts or through FUSE
+var networkFSMagic = map[uint32]bool{
+	0x6969:     true, // NFS
+	0x517B:     true, // SMB
+	0xFF534D42: true, // CIFS
+	0xFE534D42: true, // SMB2
+	0x01021997: true, // 9P (WSL, VM shared folders)
+	0x65735546: true, // FUSE (sshfs, rclone)
+}
+
+// isNetworkFS reports whether path resides on a network file system
+func isNetworkFS(path string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return false
+	}
+	return networkFSMagic[uint32(st.Type)]
+}
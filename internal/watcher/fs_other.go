@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package watcher
+
+import "strings"
+
+// isNetworkFS reports whether path resides on a network file system. Only
+// UNC paths (\\server\share) are detected; mapped drives need ModePoll.
+func isNetworkFS(path string) bool {
+	return strings.HasPrefix(path, `\\`) || strings.HasPrefix(path, "//")
+}
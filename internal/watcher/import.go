@@ -0,0 +1,42 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ImportPath imports a hand history file, or every hand history file below
+// a directory in name order, once and without watching it. Each processed
+// file is reported to the OnImport callback. Files left when ctx is done
+// are skipped and ctx's error is returned.
+func (w *Watcher) ImportPath(ctx context.Context, path string, opts PathOptions) error {
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %w", path, err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		states, _, err := scanTree(path, opts)
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", path, err)
+		}
+		files = make([]string, 0, len(states))
+		for file := range states {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		w.recordImport(w.processFile(ctx, file, 0))
+	}
+	return nil
+}
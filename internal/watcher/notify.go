@@ -0,0 +1,226 @@
+package watcher
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// notifySource watches directory trees using OS file system notifications.
+// fsnotify is not recursive, so every eligible subdirectory is registered
+// individually and kept in sync as directories are created and removed.
+type notifySource struct {
+	watcher  *fsnotify.Watcher
+	onChange func(path string)
+	roots    map[string]PathOptions // watched roots
+	dirs     map[string]string      // watched directory -> root it belongs to
+	mu       sync.Mutex
+}
+
+// newNotifySource creates a notification based source that calls onChange
+// for every hand history file written below a watched root
+func newNotifySource(onChange func(path string)) (*notifySource, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	s := &notifySource{
+		watcher:  fsWatcher,
+		onChange: onChange,
+		roots:    make(map[string]PathOptions),
+		dirs:     make(map[string]string),
+	}
+	go s.eventLoop()
+
+	return s, nil
+}
+
+func (s *notifySource) mode() Mode {
+	return ModeNotify
+}
+
+func (s *notifySource) add(root string, opts PathOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.addTree(root, root, opts); err != nil {
+		s.removeTree(root)
+		return err
+	}
+
+	s.roots[root] = opts
+	return nil
+}
+
+func (s *notifySource) remove(root string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeTree(root)
+	delete(s.roots, root)
+}
+
+func (s *notifySource) dirCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.dirs)
+}
+
+func (s *notifySource) close() error {
+	return s.watcher.Close()
+}
+
+// eventLoop listens for file system events until the watcher is closed
+func (s *notifySource) eventLoop() {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			s.handleEvent(event)
+
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watcher error: %v", err)
+		}
+	}
+}
+
+// handleEvent keeps the set of watched directories in sync with the tree and
+// reports hand history files that were written or created
+func (s *notifySource) handleEvent(event fsnotify.Event) {
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			s.addSubdir(event.Name)
+			return
+		}
+	}
+
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		s.removeSubdir(event.Name)
+		return
+	}
+
+	// Only process write and create events
+	if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
+		if s.accepts(event.Name) {
+			s.onChange(event.Name)
+		}
+	}
+}
+
+// addTree registers dir and every eligible directory below it under root.
+// The caller must hold s.mu.
+func (s *notifySource) addTree(root, dir string, opts PathOptions) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			log.Printf("Skipping %s: %v", path, err)
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && !opts.allows(root, path) {
+			return filepath.SkipDir
+		}
+		if _, exists := s.dirs[path]; exists {
+			return nil
+		}
+
+		if err := s.watcher.Add(path); err != nil {
+			if path == dir {
+				return err
+			}
+			log.Printf("Failed to watch subdirectory %s: %v", path, err)
+			return filepath.SkipDir
+		}
+		s.dirs[path] = root
+		return nil
+	})
+}
+
+// removeTree unregisters dir and every watched directory below it.
+// The caller must hold s.mu.
+func (s *notifySource) removeTree(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for path := range s.dirs {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			// The watch is already gone if the directory was deleted
+			_ = s.watcher.Remove(path)
+			delete(s.dirs, path)
+		}
+	}
+}
+
+// addSubdir starts watching a newly created directory if it belongs to a
+// watched tree and reports any hand history files already inside it
+func (s *notifySource) addSubdir(dir string) {
+	s.mu.Lock()
+	root, ok := s.dirs[filepath.Dir(dir)]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	opts := s.roots[root]
+	if !opts.allows(root, dir) {
+		s.mu.Unlock()
+		return
+	}
+	if err := s.addTree(root, dir, opts); err != nil {
+		s.mu.Unlock()
+		log.Printf("Failed to watch new directory %s: %v", dir, err)
+		return
+	}
+	s.mu.Unlock()
+
+	log.Printf("Now watching subdirectory: %s", dir)
+
+	// Files may have been written before the watch was registered
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && s.accepts(path) {
+			s.onChange(path)
+		}
+		return nil
+	})
+}
+
+// removeSubdir stops watching a deleted or renamed directory
+func (s *notifySource) removeSubdir(dir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.dirs[dir]; ok {
+		s.removeTree(dir)
+	}
+}
+
+// accepts reports whether filePath is a hand history file inside a watched
+// directory that is not excluded by its root's ignore patterns
+func (s *notifySource) accepts(filePath string) bool {
+	if !isHandHistoryFile(filePath) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, ok := s.dirs[filepath.Dir(filePath)]
+	if !ok {
+		return false
+	}
+	return !s.roots[root].ignores(filepath.Base(filePath))
+}
@@ -0,0 +1,192 @@
+package watcher
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileState is the part of a file's metadata used to detect changes
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// pollRoot is a directory tree rescanned on a fixed interval
+type pollRoot struct {
+	opts  PathOptions
+	files map[string]fileState
+	dirs  int
+	stop  chan struct{}
+}
+
+// pollSource watches directory trees by periodically rescanning them. It
+// works on file systems that do not deliver change notifications, such as
+// SMB/NFS mounts and some Wine setups.
+type pollSource struct {
+	onChange func(path string)
+	roots    map[string]*pollRoot
+	mu       sync.Mutex
+}
+
+// newPollSource creates a polling source that calls onChange for every hand
+// history file created or modified below a watched root
+func newPollSource(onChange func(path string)) *pollSource {
+	return &pollSource{
+		onChange: onChange,
+		roots:    make(map[string]*pollRoot),
+	}
+}
+
+func (s *pollSource) mode() Mode {
+	return ModePoll
+}
+
+func (s *pollSource) add(root string, opts PathOptions) error {
+	// The initial scan is the baseline; only later changes are reported
+	files, dirs, err := scanTree(root, opts)
+	if err != nil {
+		return err
+	}
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	pr := &pollRoot{
+		opts:  opts,
+		files: files,
+		dirs:  dirs,
+		stop:  make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if old, exists := s.roots[root]; exists {
+		close(old.stop)
+	}
+	s.roots[root] = pr
+	s.mu.Unlock()
+
+	go s.poll(root, pr, interval)
+	return nil
+}
+
+func (s *pollSource) remove(root string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pr, exists := s.roots[root]; exists {
+		close(pr.stop)
+		delete(s.roots, root)
+	}
+}
+
+func (s *pollSource) dirCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, pr := range s.roots {
+		count += pr.dirs
+	}
+	return count
+}
+
+func (s *pollSource) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for root, pr := range s.roots {
+		close(pr.stop)
+		delete(s.roots, root)
+	}
+	return nil
+}
+
+// poll rescans root every interval until it is removed
+func (s *pollSource) poll(root string, pr *pollRoot, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			files, dirs, err := scanTree(root, pr.opts)
+			if err != nil {
+				// Keep the previous snapshot so an unreachable share does
+				// not cause every file to be reported again on reconnect
+				log.Printf("Failed to poll %s: %v", root, err)
+				continue
+			}
+
+			var changed []string
+			for path, state := range files {
+				if prev, seen := pr.files[path]; !seen || prev != state {
+					changed = append(changed, path)
+				}
+			}
+
+			s.mu.Lock()
+			pr.files = files
+			pr.dirs = dirs
+			s.mu.Unlock()
+
+			for _, path := range changed {
+				select {
+				case <-pr.stop:
+					return
+				default:
+					s.onChange(path)
+				}
+			}
+
+		case <-pr.stop:
+			return
+		}
+	}
+}
+
+// scanTree collects the state of every hand history file below root along
+// with the number of directories visited
+func scanTree(root string, opts PathOptions) (map[string]fileState, int, error) {
+	files := make(map[string]fileState)
+	dirs := 0
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			log.Printf("Skipping %s: %v", path, err)
+			return nil
+		}
+
+		if d.IsDir() {
+			if path != root && !opts.allows(root, path) {
+				return filepath.SkipDir
+			}
+			dirs++
+			return nil
+		}
+
+		if !isHandHistoryFile(path) || opts.ignores(d.Name()) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			// File was removed between listing and stat
+			return nil
+		}
+		files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return files, dirs, nil
+}
@@ -0,0 +1,129 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Errors returned by fileQueue.push
+var (
+	errQueueFull   = errors.New("queue full")
+	errQueueClosed = errors.New("queue closed")
+)
+
+// fileQueue is a bounded FIFO of file paths awaiting processing. A path is
+// held at most once: pushing a path that is already queued is a no-op, and
+// pushing a path that is being processed schedules exactly one more run once
+// the current one finishes, so a file is never processed concurrently.
+type fileQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	order    []string
+	queued   map[string]bool
+	inflight map[string]bool // path -> pushed again while processing
+	limit    int
+	closed   bool
+}
+
+// newFileQueue creates a queue holding at most limit distinct paths
+func newFileQueue(limit int) *fileQueue {
+	q := &fileQueue{
+		queued:   make(map[string]bool),
+		inflight: make(map[string]bool),
+		limit:    limit,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push adds path to the queue without blocking. It returns errQueueFull
+// when the queue is full, in which case the caller should retry later, and
+// errQueueClosed once the queue no longer accepts paths.
+func (q *fileQueue) push(path string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return errQueueClosed
+	}
+	if q.queued[path] {
+		return nil
+	}
+	if _, running := q.inflight[path]; running {
+		q.inflight[path] = true
+		return nil
+	}
+	if len(q.order) >= q.limit {
+		return errQueueFull
+	}
+
+	q.enqueue(path)
+	return nil
+}
+
+// enqueue appends path to the queue. The caller must hold q.mu.
+func (q *fileQueue) enqueue(path string) {
+	q.order = append(q.order, path)
+	q.queued[path] = true
+	q.cond.Signal()
+}
+
+// pop blocks until a path is available and marks it as in flight. It returns
+// false once the queue is closed and drained, or when ctx is cancelled.
+func (q *fileQueue) pop(ctx context.Context) (string, bool) {
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		q.cond.Broadcast()
+	})
+	defer stop()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.order) == 0 && !q.closed && ctx.Err() == nil {
+		q.cond.Wait()
+	}
+	if len(q.order) == 0 || ctx.Err() != nil {
+		return "", false
+	}
+
+	path := q.order[0]
+	q.order[0] = ""
+	q.order = q.order[1:]
+	delete(q.queued, path)
+	q.inflight[path] = false
+	return path, true
+}
+
+// done marks path as processed, re-queueing it if it changed meanwhile
+func (q *fileQueue) done(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	again := q.inflight[path]
+	delete(q.inflight, path)
+	if again {
+		// Bypasses the limit: the path already held a slot while in flight
+		q.enqueue(path)
+	}
+}
+
+// close stops accepting new paths and wakes all waiting workers. Paths
+// already queued are still handed out by pop.
+func (q *fileQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// len returns the number of queued paths
+func (q *fileQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.order)
+}
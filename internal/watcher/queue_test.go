@@ -0,0 +1,102 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFileQueueDedup(t *testing.T) {
+	q := newFileQueue(10)
+	for i := 0; i < 3; i++ {
+		if err := q.push("a.txt"); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+	if n := q.len(); n != 1 {
+		t.Fatalf("len = %d after pushing the same path 3 times, want 1", n)
+	}
+
+	path, ok := q.pop(context.Background())
+	if !ok || path != "a.txt" {
+		t.Fatalf("pop = %q, %v", path, ok)
+	}
+
+	// Pushed again while in flight: exactly one more run once done
+	q.push("a.txt")
+	q.push("a.txt")
+	if n := q.len(); n != 0 {
+		t.Fatalf("len = %d while in flight, want 0", n)
+	}
+	q.done("a.txt")
+	if n := q.len(); n != 1 {
+		t.Fatalf("len = %d after done, want 1", n)
+	}
+	path, _ = q.pop(context.Background())
+	q.done(path)
+	if n := q.len(); n != 0 {
+		t.Fatalf("len = %d after second run, want 0", n)
+	}
+}
+
+func TestFileQueueFull(t *testing.T) {
+	q := newFileQueue(2)
+	q.push("a.txt")
+	q.push("b.txt")
+	if err := q.push("c.txt"); err != errQueueFull {
+		t.Fatalf("push to full queue = %v, want errQueueFull", err)
+	}
+	// Queued paths are still accepted as duplicates
+	if err := q.push("a.txt"); err != nil {
+		t.Fatalf("push of queued path to full queue = %v", err)
+	}
+
+	q.pop(context.Background())
+	if err := q.push("c.txt"); err != nil {
+		t.Fatalf("push after a slot freed = %v", err)
+	}
+}
+
+func TestFileQueuePopBlocksUntilCancelled(t *testing.T) {
+	q := newFileQueue(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	result := make(chan bool)
+	go func() {
+		_, ok := q.pop(ctx)
+		result <- ok
+	}()
+
+	select {
+	case <-result:
+		t.Fatal("pop returned from an empty queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case ok := <-result:
+		if ok {
+			t.Fatal("pop returned a path after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pop did not return after cancel")
+	}
+}
+
+func TestFileQueueClosed(t *testing.T) {
+	q := newFileQueue(10)
+	q.push("a.txt")
+	q.close()
+
+	if err := q.push("b.txt"); err != errQueueClosed {
+		t.Fatalf("push after close = %v, want errQueueClosed", err)
+	}
+	// Paths queued before close are still handed out
+	if path, ok := q.pop(context.Background()); !ok || path != "a.txt" {
+		t.Fatalf("pop after close = %q, %v", path, ok)
+	}
+	if _, ok := q.pop(context.Background()); ok {
+		t.Fatal("pop from closed, drained queue returned a path")
+	}
+}
@@ -0,0 +1,91 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mode selects how changes below a watched directory are detected
+type Mode string
+
+const (
+	// ModeAuto uses file system notifications unless the directory is on a
+	// network file system or notifications cannot be set up
+	ModeAuto Mode = "auto"
+	// ModeNotify always uses file system notifications
+	ModeNotify Mode = "notify"
+	// ModePoll periodically rescans the directory tree
+	ModePoll Mode = "poll"
+)
+
+// DefaultPollInterval is used for polled directories without an interval
+const DefaultPollInterval = 5 * time.Second
+
+// PathOptions controls which parts of a watched directory tree are monitored
+// and how
+type PathOptions struct {
+	// MaxDepth limits how many levels of subdirectories below the root are
+	// watched. Zero means no limit.
+	MaxDepth int
+	// Ignore holds filepath.Match patterns; files and directories whose
+	// name matches any of them are skipped
+	Ignore []string
+	// Mode selects the change detection strategy, defaulting to ModeAuto
+	Mode Mode
+	// PollInterval is the rescan interval in ModePoll, defaulting to
+	// DefaultPollInterval
+	PollInterval time.Duration
+}
+
+// allows reports whether the directory at path below root should be watched
+func (o PathOptions) allows(root, path string) bool {
+	if o.ignores(filepath.Base(path)) {
+		return false
+	}
+	if o.MaxDepth > 0 {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return false
+		}
+		if strings.Count(rel, string(filepath.Separator))+1 > o.MaxDepth {
+			return false
+		}
+	}
+	return true
+}
+
+// ignores reports whether name matches one of the ignore patterns
+func (o PathOptions) ignores(name string) bool {
+	for _, pattern := range o.Ignore {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// source detects changed hand history files below a set of root directories
+// and reports them to the watcher pipeline
+type source interface {
+	// mode returns the detection strategy implemented by the source
+	mode() Mode
+	// add starts watching the tree below root
+	add(root string, opts PathOptions) error
+	// remove stops watching the tree below root
+	remove(root string)
+	// dirCount returns the number of directories currently monitored
+	dirCount() int
+	// close releases all resources held by the source
+	close() error
+}
+
+// isHandHistoryFile reports whether path looks like a hand history file
+func isHandHistoryFile(path string) bool {
+	// PokerStars hand histories are .txt, Open Hand History files .ohh
+	switch filepath.Ext(path) {
+	case ".txt", ".ohh":
+		return true
+	}
+	return false
+}
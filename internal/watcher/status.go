@@ -0,0 +1,116 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"aniki/internal/database"
+)
+
+// Status is a snapshot of the watcher state
+type Status struct {
+	IsRunning   bool         `json:"is_running"`
+	QueueLength int          `json:"queue_length"`
+	Workers     int          `json:"workers"`
+	WatchedDirs int          `json:"watched_dirs"`
+	Paths       []PathStatus `json:"paths"`
+}
+
+// PathStatus holds import counters for a watched root directory
+type PathStatus struct {
+	Path           string     `json:"path"`
+	Mode           Mode       `json:"mode"`
+	FilesProcessed int        `json:"files_processed"`
+	HandsSaved     int        `json:"hands_saved"`
+	HandsSkipped   int        `json:"hands_skipped"`
+	HandsFailed    int        `json:"hands_failed"`
+	LastImport     *time.Time `json:"last_import,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// ImportEvent describes the outcome of importing a single file
+type ImportEvent struct {
+	Path    string    `json:"path"` // watched root the file belongs to
+	File    string    `json:"file"`
+	Site    string    `json:"site"`
+	Saved   int       `json:"saved"`
+	Skipped int       `json:"skipped"`
+	Failed  int       `json:"failed"`
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error,omitempty"`
+	// Latest holds the newest hand of each table in the file when any
+	// hands were saved, for live consumers such as the HUD
+	Latest []database.Hand `json:"-"`
+}
+
+// GetStatus returns the current status of the watcher
+func (w *Watcher) GetStatus() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := Status{
+		IsRunning:   w.isRunning,
+		QueueLength: w.queue.len(),
+		Workers:     w.opts.Workers,
+		WatchedDirs: w.notifier.dirCount() + w.poller.dirCount(),
+		Paths:       make([]PathStatus, 0, len(w.paths)),
+	}
+
+	for path, src := range w.paths {
+		ps := PathStatus{Path: path}
+		if counters, ok := w.counters[path]; ok {
+			ps = *counters
+		}
+		ps.Mode = src.mode()
+		status.Paths = append(status.Paths, ps)
+	}
+	sort.Slice(status.Paths, func(i, j int) bool {
+		return status.Paths[i].Path < status.Paths[j].Path
+	})
+
+	return status
+}
+
+// recordImport updates the counters of the root event.File belongs to and
+// notifies the OnImport callback
+func (w *Watcher) recordImport(event ImportEvent) {
+	event.Time = time.Now()
+
+	w.mu.Lock()
+	event.Path = w.rootOf(event.File)
+	counters, ok := w.counters[event.Path]
+	if !ok {
+		counters = &PathStatus{Path: event.Path}
+		w.counters[event.Path] = counters
+	}
+	counters.FilesProcessed++
+	counters.HandsSaved += event.Saved
+	counters.HandsSkipped += event.Skipped
+	counters.HandsFailed += event.Failed
+	if event.Saved > 0 {
+		importedAt := event.Time
+		counters.LastImport = &importedAt
+	}
+	if event.Error != "" {
+		counters.LastError = event.Error
+	}
+	w.mu.Unlock()
+
+	if w.opts.OnImport != nil {
+		w.opts.OnImport(event)
+	}
+}
+
+// rootOf returns the watched root containing filePath, preferring the most
+// specific one. The caller must hold w.mu.
+func (w *Watcher) rootOf(filePath string) string {
+	best := ""
+	for root := range w.paths {
+		if strings.HasPrefix(filePath, root+string(filepath.Separator)) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
@@ -1,6 +1,7 @@
 package watcher
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -11,84 +12,166 @@ import (
 	"aniki/internal/database"
 	"aniki/internal/hand_history"
 	"aniki/internal/repository"
-
-	"github.com/fsnotify/fsnotify"
 )
 
 // Watcher monitors directories for hand history files
 type Watcher struct {
-	watcher      *fsnotify.Watcher
-	parser       *hand_history.Manager
-	siteRepo     repository.SiteRepository
-	handRepo     repository.HandRepository
-	playerRepo   repository.PlayerRepository
-	actionRepo   repository.ActionRepository
-	paths        map[string]bool
-	mu           sync.Mutex
-	debounceMap  map[string]*time.Timer
-	debounceMu   sync.Mutex
-	stopCh       chan struct{}
-	processingCh chan string
-	workerCount  int
-	isRunning    bool
+	notifier    *notifySource
+	poller      *pollSource
+	parser      *hand_history.Manager
+	siteRepo    repository.SiteRepository
+	handRepo    repository.HandRepository
+	playerRepo  repository.PlayerRepository
+	actionRepo  repository.ActionRepository
+	paths       map[string]source // watched roots -> source monitoring them
+	counters    map[string]*PathStatus
+	mu          sync.Mutex
+	debounceMap map[string]*debounceEntry
+	debounceMu  sync.Mutex
+	debounceOff bool
+	queue       *fileQueue
+	workers     sync.WaitGroup
+	ctx         context.Context
+	cancel      context.CancelFunc
+	opts        Options
+	isRunning   bool
+}
+
+// Options tunes the processing pipeline. Zero values select the defaults.
+type Options struct {
+	// Workers is the number of files processed concurrently (default 3)
+	Workers int
+	// QueueSize bounds the number of distinct files waiting to be
+	// processed (default 1000). Files that do not fit are retried after
+	// the debounce delay instead of blocking the watcher.
+	QueueSize int
+	// DebounceDelay is how long a file must stay unchanged before it is
+	// processed (default 1s)
+	DebounceDelay time.Duration
+	// OnImport, if set, is called from a worker goroutine after every
+	// processed file
+	OnImport func(ImportEvent)
+}
+
+// withDefaults fills in zero fields with default values
+func (o Options) withDefaults() Options {
+	if o.Workers <= 0 {
+		o.Workers = 3
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	if o.DebounceDelay <= 0 {
+		o.DebounceDelay = time.Second
+	}
+	return o
+}
+
+// debounceEntry is a pending debounce timer for a single file
+type debounceEntry struct {
+	timer *time.Timer
 }
 
 // New creates a new file watcher
-func New(parser *hand_history.Manager, siteRepo repository.SiteRepository, handRepo repository.HandRepository, playerRepo repository.PlayerRepository, actionRepo repository.ActionRepository) (*Watcher, error) {
-	fsWatcher, err := fsnotify.NewWatcher()
+func New(parser *hand_history.Manager, siteRepo repository.SiteRepository, handRepo repository.HandRepository, playerRepo repository.PlayerRepository, actionRepo repository.ActionRepository, opts Options) (*Watcher, error) {
+	opts = opts.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := &Watcher{
+		parser:      parser,
+		siteRepo:    siteRepo,
+		handRepo:    handRepo,
+		playerRepo:  playerRepo,
+		actionRepo:  actionRepo,
+		paths:       make(map[string]source),
+		counters:    make(map[string]*PathStatus),
+		debounceMap: make(map[string]*debounceEntry),
+		queue:       newFileQueue(opts.QueueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+		opts:        opts,
+		isRunning:   false,
+	}
+
+	notifier, err := newNotifySource(w.debounceFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create watcher: %w", err)
-	}
-
-	return &Watcher{
-		watcher:      fsWatcher,
-		parser:       parser,
-		siteRepo:     siteRepo,
-		handRepo:     handRepo,
-		playerRepo:   playerRepo,
-		actionRepo:   actionRepo,
-		paths:        make(map[string]bool),
-		debounceMap:  make(map[string]*time.Timer),
-		stopCh:       make(chan struct{}),
-		processingCh: make(chan string, 100), // Buffer for file paths
-		workerCount:  3,                      // 3 concurrent workers
-		isRunning:    false,
-	}, nil
+		cancel()
+		return nil, err
+	}
+	w.notifier = notifier
+	w.poller = newPollSource(w.debounceFile)
+
+	return w, nil
 }
 
-// AddPath adds a directory to watch
-func (w *Watcher) AddPath(path string) error {
+// AddPath adds a directory and its subdirectories to watch
+func (w *Watcher) AddPath(path string, opts PathOptions) error {
+	path = filepath.Clean(path)
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.paths[path] {
+	if _, exists := w.paths[path]; exists {
 		return nil // Already watching
 	}
 
-	err := w.watcher.Add(path)
+	src, err := w.watchWith(path, opts)
 	if err != nil {
 		return fmt.Errorf("failed to watch path %s: %w", path, err)
 	}
 
-	w.paths[path] = true
-	log.Printf("Now watching: %s", path)
+	w.paths[path] = src
+	log.Printf("Now watching: %s (%s)", path, src.mode())
 	return nil
 }
 
-// RemovePath stops watching a directory
+// watchWith registers path with the source selected by opts.Mode
+func (w *Watcher) watchWith(path string, opts PathOptions) (source, error) {
+	switch opts.Mode {
+	case ModeNotify:
+		if err := w.notifier.add(path, opts); err != nil {
+			return nil, err
+		}
+		return w.notifier, nil
+
+	case ModePoll:
+		if err := w.poller.add(path, opts); err != nil {
+			return nil, err
+		}
+		return w.poller, nil
+
+	case ModeAuto, "":
+		if isNetworkFS(path) {
+			log.Printf("%s is on a network file system, polling for changes", path)
+		} else if err := w.notifier.add(path, opts); err != nil {
+			log.Printf("File notifications unavailable for %s, polling instead: %v", path, err)
+		} else {
+			return w.notifier, nil
+		}
+
+		if err := w.poller.add(path, opts); err != nil {
+			return nil, err
+		}
+		return w.poller, nil
+
+	default:
+		return nil, fmt.Errorf("unknown watch mode %q", opts.Mode)
+	}
+}
+
+// RemovePath stops watching a directory and its subdirectories
 func (w *Watcher) RemovePath(path string) error {
+	path = filepath.Clean(path)
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if !w.paths[path] {
+	src, exists := w.paths[path]
+	if !exists {
 		return nil // Not watching
 	}
 
-	err := w.watcher.Remove(path)
-	if err != nil {
-		return fmt.Errorf("failed to unwatch path %s: %w", path, err)
-	}
-
+	src.remove(path)
 	delete(w.paths, path)
 	log.Printf("Stopped watching: %s", path)
 	return nil
@@ -105,156 +188,230 @@ func (w *Watcher) Start() {
 	w.mu.Unlock()
 
 	// Start worker pool
-	for i := 0; i < w.workerCount; i++ {
+	for i := 0; i < w.opts.Workers; i++ {
+		w.workers.Add(1)
 		go w.worker(i)
 	}
 
-	// Start event listener
-	go w.eventLoop()
-
 	log.Println("File watcher started")
 }
 
-// Stop stops the file watcher
-func (w *Watcher) Stop() {
+// Stop stops watching and drains the queue. Files waiting for their
+// debounce delay are queued immediately, and workers keep processing until
+// the queue is empty or ctx is done, at which point in-progress files are
+// abandoned between hands and ctx's error is returned. A watcher that was
+// never started only releases its file notifications.
+func (w *Watcher) Stop(ctx context.Context) error {
 	w.mu.Lock()
 	if !w.isRunning {
 		w.mu.Unlock()
-		return
+		w.notifier.close()
+		w.poller.close()
+		w.cancel()
+		return nil
 	}
 	w.isRunning = false
 	w.mu.Unlock()
 
-	close(w.stopCh)
-	w.watcher.Close()
-	close(w.processingCh)
+	// No new events past this point
+	w.notifier.close()
+	w.poller.close()
+	w.flushDebounced()
+	w.queue.close()
+
+	drained := make(chan struct{})
+	go func() {
+		w.workers.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Printf("File watcher drain interrupted, %d files left unprocessed", w.queue.len())
+	}
+	w.cancel()
+	<-drained
 
 	log.Println("File watcher stopped")
+	return err
 }
 
-// eventLoop listens for file system events
-func (w *Watcher) eventLoop() {
-	for {
-		select {
-		case event, ok := <-w.watcher.Events:
-			if !ok {
-				return
-			}
-
-			// Only process write and create events
-			if event.Op&fsnotify.Write == fsnotify.Write ||
-				event.Op&fsnotify.Create == fsnotify.Create {
-				// Only process .txt files (PokerStars hand histories)
-				if filepath.Ext(event.Name) == ".txt" {
-					w.debounceFile(event.Name)
-				}
-			}
-
-		case err, ok := <-w.watcher.Errors:
-			if !ok {
-				return
-			}
-			log.Printf("Watcher error: %v", err)
-
-		case <-w.stopCh:
-			return
-		}
-	}
-}
-
-// debounceFile implements debouncing to avoid processing incomplete files
+// debounceFile implements debouncing to avoid processing incomplete files.
+// It never blocks: the file is queued from a timer once it settles.
 func (w *Watcher) debounceFile(filePath string) {
 	w.debounceMu.Lock()
 	defer w.debounceMu.Unlock()
 
+	if w.debounceOff {
+		return
+	}
+
 	// Cancel existing timer for this file
-	if timer, exists := w.debounceMap[filePath]; exists {
-		timer.Stop()
+	if entry, exists := w.debounceMap[filePath]; exists {
+		entry.timer.Stop()
 	}
 
 	// Create new timer that will trigger processing after delay
-	w.debounceMap[filePath] = time.AfterFunc(1*time.Second, func() {
-		w.processingCh <- filePath
-
+	entry := &debounceEntry{}
+	entry.timer = time.AfterFunc(w.opts.DebounceDelay, func() {
 		w.debounceMu.Lock()
+		if w.debounceMap[filePath] != entry {
+			// Superseded by a newer event or flushed by Stop
+			w.debounceMu.Unlock()
+			return
+		}
 		delete(w.debounceMap, filePath)
 		w.debounceMu.Unlock()
+
+		switch err := w.queue.push(filePath); err {
+		case errQueueFull:
+			// Back off and try again later
+			w.debounceFile(filePath)
+		case errQueueClosed:
+			log.Printf("Watcher stopped, not importing: %s", filePath)
+		}
 	})
+	w.debounceMap[filePath] = entry
 }
 
-// worker processes files from the queue
+// flushDebounced stops all debounce timers and queues their files
+func (w *Watcher) flushDebounced() {
+	w.debounceMu.Lock()
+	defer w.debounceMu.Unlock()
+
+	w.debounceOff = true
+	for filePath, entry := range w.debounceMap {
+		entry.timer.Stop()
+		delete(w.debounceMap, filePath)
+		if err := w.queue.push(filePath); err != nil {
+			log.Printf("Skipping %s during shutdown: %v", filePath, err)
+		}
+	}
+}
+
+// worker processes files from the queue until it is drained or cancelled
 func (w *Watcher) worker(id int) {
-	for {
-		select {
-		case filePath, ok := <-w.processingCh:
-			if !ok {
-				return
-			}
-			w.processFile(filePath, id)
+	defer w.workers.Done()
 
-		case <-w.stopCh:
+	for {
+		filePath, ok := w.queue.pop(w.ctx)
+		if !ok {
 			return
 		}
+		event := w.processFile(w.ctx, filePath, id)
+		w.queue.done(filePath)
+		w.recordImport(event)
 	}
 }
 
 // processFile parses and saves a hand history file
-func (w *Watcher) processFile(filePath string, workerID int) {
+func (w *Watcher) processFile(ctx context.Context, filePath string, workerID int) ImportEvent {
 	log.Printf("Worker %d: Processing file: %s", workerID, filePath)
 
+	event := ImportEvent{File: filePath}
+
 	// Parse the file
 	hands, siteName, err := w.parser.ParseFile(filePath)
+	event.Site = siteName
 	if err != nil {
 		log.Printf("Worker %d: Error parsing file %s: %v", workerID, filePath, err)
-		return
+		event.Error = fmt.Sprintf("failed to parse %s: %v", filepath.Base(filePath), err)
+		return event
 	}
 
 	if len(hands) == 0 {
 		log.Printf("Worker %d: No hands found in file: %s", workerID, filePath)
-		return
+		return event
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("Worker %d: Cancelled before saving %s", workerID, filePath)
+		return event
+	}
+
+	// Convert hand_history.Hand to database.Hand. Formats such as OHH name
+	// the site of each hand; other hands belong to the parser's site.
+	sites := map[string]*database.Site{}
+	dbHands := make([]database.Hand, len(hands))
+	for i := range hands {
+		name := hands[i].Site
+		if name == "" {
+			name = siteName
+		}
+		site, ok := sites[name]
+		if !ok {
+			site, err = w.findSite(name, hands[i].Site != "")
+			if err != nil {
+				log.Printf("Worker %d: Error getting site %s: %v", workerID, name, err)
+				event.Error = fmt.Sprintf("failed to get site %s: %v", name, err)
+				event.Failed = len(hands)
+				return event
+			}
+			sites[name] = site
+		}
+		dbHands[i] = convertToDBHand(&hands[i], site.ID)
 	}
 
-	// Get site from database
-	site, err := w.siteRepo.FindByName(siteName)
+	// Save all hands in one transaction, skipping duplicates
+	saved, skipped, err := w.handRepo.CreateBatch(dbHands)
 	if err != nil {
-		log.Printf("Worker %d: Error getting site %s: %v", workerID, siteName, err)
-		return
+		log.Printf("Worker %d: Error saving hands from %s: %v", workerID, filePath, err)
+		event.Error = fmt.Sprintf("failed to save hands: %v", err)
+		event.Failed = len(hands)
+		return event
 	}
-	if site == nil {
-		log.Printf("Worker %d: Site not found: %s", workerID, siteName)
-		return
+	event.Saved = saved
+	event.Skipped = skipped
+	if saved > 0 {
+		event.Latest = latestPerTable(dbHands)
 	}
 
-	// Process each hand
-	saved := 0
-	skipped := 0
-	for _, hand := range hands {
-		// Check if hand already exists
-		exists, err := w.handRepo.Exists(site.ID, hand.HandID)
-		if err != nil {
-			log.Printf("Worker %d: Error checking hand existence: %v", workerID, err)
-			continue
-		}
+	log.Printf("Worker %d: Processed %s - Saved: %d, Skipped: %d, Failed: %d", workerID, filepath.Base(filePath), event.Saved, event.Skipped, event.Failed)
+	return event
+}
 
-		if exists {
-			skipped++
-			continue
-		}
+// findSite returns the named site. Missing sites are an error unless
+// create is set, in which case they are added.
+func (w *Watcher) findSite(name string, create bool) (*database.Site, error) {
+	site, err := w.siteRepo.FindByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if site != nil {
+		return site, nil
+	}
+	if !create {
+		return nil, fmt.Errorf("site not found: %s", name)
+	}
 
-		// Convert hand_history.Hand to database.Hand
-		dbHand := convertToDBHand(&hand, site.ID)
+	site = &database.Site{Name: name, Enabled: true}
+	if err := w.siteRepo.Create(site); err != nil {
+		return nil, fmt.Errorf("failed to create site: %w", err)
+	}
+	log.Printf("Created site: %s", name)
+	return site, nil
+}
 
-		// Save hand
-		err = w.handRepo.Create(&dbHand)
-		if err != nil {
-			log.Printf("Worker %d: Error saving hand %s: %v", workerID, hand.HandID, err)
+// latestPerTable returns the newest hand of each table, in order of first
+// appearance
+func latestPerTable(hands []database.Hand) []database.Hand {
+	index := map[string]int{}
+	var latest []database.Hand
+	for _, hand := range hands {
+		i, ok := index[hand.TableName]
+		if !ok {
+			index[hand.TableName] = len(latest)
+			latest = append(latest, hand)
 			continue
 		}
-
-		saved++
+		if !hand.DateTime.Before(latest[i].DateTime) {
+			latest[i] = hand
+		}
 	}
-
-	log.Printf("Worker %d: Processed %s - Saved: %d, Skipped: %d", workerID, filepath.Base(filePath), saved, skipped)
+	return latest
 }
 
 // convertToDBHand converts a hand_history.Hand to a database.Hand
@@ -268,33 +425,61 @@ func convertToDBHand(hand *hand_history.Hand, siteID int) database.Hand {
 	// Convert full hand to JSON for parsed_data
 	parsedDataJSON, _ := json.Marshal(hand)
 
-	return database.Hand{
-		SiteID:     siteID,
-		HandID:     hand.HandID,
-		GameType:   hand.GameType,
-		Stakes:     hand.Stakes,
-		TableName:  hand.TableName,
-		DateTime:   hand.DateTime,
-		HeroName:   hand.HeroName,
-		Position:   hand.Position,
-		HoleCards:  string(holeCardsJSON),
-		Board:      string(boardJSON),
-		Result:     hand.Result,
-		Rake:       hand.Rake,
-		TotalPot:   hand.TotalPot,
-		ParsedData: string(parsedDataJSON),
-		RawText:    hand.RawText,
+	players := make([]database.Player, len(hand.Players))
+	for i, p := range hand.Players {
+		players[i] = database.Player{
+			Name:     p.Name,
+			Seat:     p.Seat,
+			Stack:    p.Stack,
+			Position: p.Position,
+			MadeHand: p.MadeHand,
+		}
+		if len(p.Cards) > 0 {
+			cardsJSON, _ := json.Marshal(p.Cards)
+			players[i].Cards = string(cardsJSON)
+		}
 	}
-}
 
-// GetStatus returns the current status of the watcher
-func (w *Watcher) GetStatus() map[string]interface{} {
-	w.mu.Lock()
-	defer w.mu.Unlock()
+	actions := make([]database.Action, len(hand.Actions))
+	for i, a := range hand.Actions {
+		actions[i] = database.Action{
+			PlayerName: a.PlayerName,
+			Action:     a.Action,
+			Amount:     a.Amount,
+			Street:     a.Street,
+			Sequence:   a.Sequence,
+			AllIn:      a.AllIn,
+		}
+	}
 
-	return map[string]interface{}{
-		"is_running":    w.isRunning,
-		"watched_paths": w.paths,
-		"queue_length":  len(w.processingCh),
+	dbHand := database.Hand{
+		SiteID:      siteID,
+		HandID:      hand.HandID,
+		GameType:    hand.GameType,
+		Stakes:      hand.Stakes,
+		TableName:   hand.TableName,
+		DateTime:    hand.DateTime,
+		HeroName:    hand.HeroName,
+		Position:    hand.Position,
+		HoleCards:   string(holeCardsJSON),
+		Board:       string(boardJSON),
+		BigBlind:    hand.BigBlind,
+		Result:      hand.Result,
+		AllInEquity: hand.AllInEquity,
+		AllInEV:     hand.AllInEV,
+		Rake:        hand.Rake,
+		TotalPot:    hand.TotalPot,
+		ParsedData:  string(parsedDataJSON),
+		RawText:     hand.RawText,
+		Players:     players,
+		Actions:     actions,
+	}
+	dbHand.SetCards(hand.HoleCards, hand.Board)
+	dbHand.SetPreflopStats()
+	for _, p := range hand.Players {
+		if len(p.Cards) > 0 {
+			dbHand.AddShownCards(p.Name, p.Cards)
+		}
 	}
+	return dbHand
 }
@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"aniki/internal/hand_history"
+)
+
+// newTestWatcher creates a watcher without repositories. The files used in
+// tests do not exist, so processing stops at parsing.
+func newTestWatcher(t *testing.T, opts Options) *Watcher {
+	t.Helper()
+	w, err := New(hand_history.NewManager(), nil, nil, nil, nil, opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return w
+}
+
+func TestStopFlushesDebouncedFiles(t *testing.T) {
+	var mu sync.Mutex
+	var imported []string
+	w := newTestWatcher(t, Options{
+		Workers:       2,
+		DebounceDelay: time.Hour, // only Stop can queue the files
+		OnImport: func(event ImportEvent) {
+			mu.Lock()
+			imported = append(imported, event.File)
+			mu.Unlock()
+		},
+	})
+	w.Start()
+
+	w.debounceFile("/missing/a.txt")
+	w.debounceFile("/missing/b.txt")
+	w.debounceFile("/missing/a.txt")
+
+	if err := w.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if len(imported) != 2 {
+		t.Fatalf("processed %v, want both debounced files once", imported)
+	}
+}
+
+func TestStopReturnsOnTimeout(t *testing.T) {
+	var w *Watcher
+	var mu sync.Mutex
+	processed := 0
+	w = newTestWatcher(t, Options{
+		Workers:       1,
+		DebounceDelay: time.Hour,
+		OnImport: func(ImportEvent) {
+			mu.Lock()
+			processed++
+			mu.Unlock()
+			// Hold the only worker until Stop gives up waiting
+			<-w.ctx.Done()
+		},
+	})
+	w.Start()
+
+	for _, path := range []string{"/missing/a.txt", "/missing/b.txt", "/missing/c.txt"} {
+		w.debounceFile(path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- w.Stop(ctx) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Stop = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return after its context expired")
+	}
+	if processed != 1 {
+		t.Fatalf("processed %d files, want the remaining ones abandoned", processed)
+	}
+}
+
+func TestDebouncedFileAfterStopIsDropped(t *testing.T) {
+	w := newTestWatcher(t, Options{Workers: 1, DebounceDelay: time.Hour})
+	w.Start()
+	if err := w.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	w.debounceFile("/missing/a.txt")
+	if n := w.queue.len(); n != 0 {
+		t.Fatalf("queue holds %d files after Stop", n)
+	}
+	if err := w.queue.push("/missing/a.txt"); err != errQueueClosed {
+		t.Fatalf("push after Stop = %v, want errQueueClosed", err)
+	}
+}
+
+func TestStopWithoutStart(t *testing.T) {
+	w := newTestWatcher(t, Options{})
+	if err := w.AddPath(t.TempDir(), PathOptions{Mode: ModeNotify}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if w.ctx.Err() == nil {
+		t.Error("context not cancelled")
+	}
+	// Stopping again is harmless
+	if err := w.Stop(context.Background()); err != nil {
+		t.Fatalf("second Stop: %v", err)
+	}
+}